@@ -0,0 +1,59 @@
+// Comando repair: revisa la consistencia del historial de todas las órdenes
+// (status vs registro actual) y opcionalmente la repara. Escribe un reporte JSON.
+//
+//	go run ./cmd/repair                      # sólo reporta, en stdout
+//	go run ./cmd/repair -fix -out report.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"order-status-service-2/internal/config"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "reparar las órdenes inconsistentes (por orden de timestamp)")
+	out := flag.String("out", "", "archivo donde guardar el reporte JSON (por defecto, stdout)")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	ctx := context.Background()
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+
+	repo := repository.NewMongoOrderRepository(client.Database(cfg.MongoDBName))
+	report, err := service.NewConsistencyChecker(repo).Run(ctx, *fix)
+	if err != nil {
+		log.Fatalf("Error revisando órdenes: %v", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(append(data, '\n'))
+	} else if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("Error guardando el reporte: %v", err)
+	}
+
+	log.Printf("Órdenes revisadas: %d, inconsistentes: %d, reparadas: %d, con error: %d",
+		report.Scanned, report.Invalid, report.Fixed, report.Failed)
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,32 +15,69 @@ import (
 
 	"order-status-service-2/internal/config"
 	"order-status-service-2/internal/controller"
+	"order-status-service-2/internal/hooks"
 	"order-status-service-2/internal/middleware"
+	"order-status-service-2/internal/migrations"
 	"order-status-service-2/internal/rabbit"
 	"order-status-service-2/internal/repository"
 	"order-status-service-2/internal/service"
 )
 
 func main() {
-	cfg := config.Load()
+	migrateOnly := flag.Bool("migrate", false, "aplica las migraciones de esquema pendientes y termina")
+	flag.Parse()
 
-	// Conexión a MongoDB
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	cfg := config.Load()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	// Workflow de estados versionado: si es inválido, no se levanta el servicio
+	workflows, err := service.LoadWorkflowFile(cfg.WorkflowFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	db := client.Database(cfg.MongoDBName)
 
-	// Repositorio y servicios
-	repo := repository.NewMongoOrderRepository(db)
-	orderService := service.NewOrderStatusService(repo)
+	// Almacenamiento: MongoDB o memoria (STORAGE=memory)
+	var st *storage
+	switch cfg.Storage {
+	case "mongo":
+		st = openMongo(cfg, *migrateOnly)
+	case "memory":
+		if *migrateOnly {
+			log.Fatal("-migrate no aplica con STORAGE=memory")
+		}
+		log.Println("⚠️ STORAGE=memory: los datos se pierden al reiniciar")
+		st = openMemory()
+	default:
+		log.Fatalf("STORAGE inválido: %q (mongo o memory)", cfg.Storage)
+	}
+	if st == nil {
+		return // sólo migraciones
+	}
+
+	// Repositorio y servicios. El plazo del Seed corre recién ahora, después de las
+	// migraciones (que pueden tardar lo que haga falta).
+	repo := st.orders
+	catalogService := service.NewStateCatalogService(st.catalog, repo, st.schedules)
+	seedCtx, cancelSeed := context.WithTimeout(context.Background(), 10*time.Second)
+	err = catalogService.Seed(seedCtx, workflows)
+	cancelSeed()
+	if err != nil {
+		log.Fatalf("Error inicializando catálogo de estados: %v", err)
+	}
+	reasonCodes := service.NewReasonCodeCatalog(workflows.ReasonCodes)
+	orderService := service.NewOrderStatusService(repo, catalogService, reasonCodes, st.files, service.OrderPolicy{
+		RevertWindow:        cfg.RevertWindow,
+		ReturnWindow:        cfg.ReturnWindow,
+		BulkConcurrency:     cfg.BulkConcurrency,
+		ProofMaxFileSize:    int64(cfg.ProofMaxFileSize),
+		MaxDeliveryAttempts: cfg.MaxDeliveryAttempts,
+	})
 	authService := service.NewAuthService()
+	transitionScheduler := service.NewTransitionScheduler(orderService, st.schedules, st.locks, cfg.ScheduleWorkerInterval)
 
-	// Controller
+	// Controllers
 	ctrl := controller.NewOrderController(orderService)
+	catalogCtrl := controller.NewCatalogController(catalogService, reasonCodes)
+	scheduleCtrl := controller.NewScheduleController(transitionScheduler)
 
 	// Router
 	r := gin.Default()
@@ -52,13 +92,58 @@ func main() {
 	auth.PATCH("/orders/:orderId/status", ctrl.UpdateStatus)
 	auth.GET("/orders/mine", ctrl.GetMyOrders)
 	auth.GET("/orders/:orderId/latest", ctrl.GetLatestStatus)
+	auth.POST("/orders/:orderId/returns", ctrl.RequestReturn)
+	auth.POST("/orders/:orderId/proof-of-delivery", ctrl.DeliverWithProof)
+	auth.POST("/orders/:orderId/delivery-attempts", ctrl.RecordDeliveryAttempt)
+	auth.GET("/orders/:orderId/proof-of-delivery/:fileId", ctrl.DownloadProofFile)
+
+	// Repartidores: sólo sus órdenes asignadas
+	courier := auth.Group("/courier", middleware.RequireRole(service.RoleCourier))
+	courier.GET("/orders", ctrl.GetCourierOrders)
 
-	// Rutas admin
+	// Rutas de gestión: cada grupo exige alguno de los roles indicados
 	admin := auth.Group("/admin")
-	admin.Use(middleware.AdminOnly())
-	admin.GET("/orders/all", ctrl.GetAllOrders)
-	admin.GET("/orders/:state", ctrl.GetAllOrdersByState)
-	admin.GET("/orders-with-status", ctrl.GetAllOrdersWithLatest)
+
+	// Lectura de órdenes: admin, soporte y depósito
+	readers := admin.Group("", middleware.RequireRole(service.RoleAdmin, service.RoleSupport, service.RoleWarehouse))
+	readers.GET("/orders/all", ctrl.GetAllOrders)
+	readers.GET("/orders/search", ctrl.SearchOrders)
+	readers.GET("/orders/:state", ctrl.GetAllOrdersByState)
+	readers.GET("/orders-with-status", ctrl.GetAllOrdersWithLatest)
+
+	// Preparación y despacho: admin y depósito
+	warehouse := admin.Group("", middleware.RequireRole(service.RoleAdmin, service.RoleWarehouse))
+	warehouse.POST("/orders/bulk-status", ctrl.BulkUpdateStatus)
+	warehouse.PATCH("/orders/:orderId/items/:articleId/status", ctrl.UpdateItemStatus)
+	warehouse.POST("/orders/:orderId/shipments", ctrl.CreateShipment)
+
+	// Entrega de paquetes: admin, depósito y repartidores
+	delivery := admin.Group("", middleware.RequireRole(service.RoleAdmin, service.RoleWarehouse, service.RoleCourier))
+	delivery.PATCH("/orders/:orderId/shipments/:shipmentId/status", ctrl.UpdateShipmentStatus)
+
+	// Reanudar órdenes en espera: admin y soporte
+	support := admin.Group("", middleware.RequireRole(service.RoleAdmin, service.RoleSupport))
+	support.POST("/orders/:orderId/resume", ctrl.ResumeOrder)
+
+	// Sólo admin
+	adminOnly := admin.Group("", middleware.AdminOnly())
+	adminOnly.POST("/orders/:orderId/revert", ctrl.RevertLastTransition)
+	adminOnly.PUT("/orders/:orderId/courier", ctrl.AssignCourier)
+	adminOnly.GET("/returns", ctrl.GetOrdersWithReturns)
+	adminOnly.PATCH("/orders/:orderId/returns/:returnId/status", ctrl.UpdateReturnStatus)
+	adminOnly.GET("/scheduled-transitions", scheduleCtrl.List)
+	adminOnly.POST("/orders/:orderId/scheduled-transitions", scheduleCtrl.Schedule)
+	adminOnly.DELETE("/orders/:orderId/scheduled-transitions/:scheduleId", scheduleCtrl.Cancel)
+
+	// Catálogo de estados y transiciones
+	adminOnly.GET("/states", catalogCtrl.GetStates)
+	adminOnly.POST("/states", catalogCtrl.CreateState)
+	adminOnly.PUT("/states/:name", catalogCtrl.UpdateState)
+	adminOnly.DELETE("/states/:name", catalogCtrl.DeleteState)
+	adminOnly.GET("/transitions", catalogCtrl.GetTransitions)
+	adminOnly.POST("/transitions", catalogCtrl.AddTransition)
+	adminOnly.DELETE("/transitions", catalogCtrl.RemoveTransition)
+	adminOnly.GET("/reason-codes", catalogCtrl.GetReasonCodes)
 
 	// Conexión a RabbitMQ
 	conn, err := amqp091.Dial(cfg.RabbitURL)
@@ -70,11 +155,82 @@ func main() {
 		log.Fatalf("Error creando canal en RabbitMQ: %v", err)
 	}
 
+	// Hooks post-transición: se registran antes de empezar a consumir, para que
+	// también corran en las transiciones que disparan los eventos de RabbitMQ.
+	orderService.RegisterHook(hooks.NewLogHook(), service.HookOptions{Mode: service.HookSync, Timeout: time.Second})
+	publisher, err := rabbit.NewStatusChangedPublisher(ch)
+	if err != nil {
+		log.Fatalf("Error declarando exchange order_status_changed: %v", err)
+	}
+	orderService.RegisterHook(publisher, service.HookOptions{Mode: service.HookAsync, Timeout: 5 * time.Second})
+
 	rabbit.SetupConsumers(ch, orderService)
 
+	// Transiciones automáticas por SLA
+	slaScheduler := service.NewSLAScheduler(orderService, repo, catalogService, st.locks, cfg.SLASchedulerInterval)
+	slaScheduler.Start(context.Background())
+
+	// Transiciones programadas por los admins
+	transitionScheduler.Start(context.Background())
+
 	// Ejecutar servidor
 	log.Printf("Order Status Service ejecutándose en puerto %s", cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// Repositorios que usa el servicio, según STORAGE
+type storage struct {
+	orders interface {
+		service.OrderRepository
+		service.StaleOrderRepository
+	}
+	catalog   service.StateCatalogRepository
+	schedules service.ScheduleRepository
+	locks     service.LockRepository
+	files     service.FileStore
+}
+
+// openMongo se conecta a MongoDB y aplica las migraciones pendientes (si
+// MIGRATE_ON_START o -migrate). Con -migrate termina ahí y devuelve nil.
+func openMongo(cfg *config.Config, migrateOnly bool) *storage {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := client.Database(cfg.MongoDBName)
+	lockRepo := repository.NewMongoLockRepository(db)
+
+	// Migraciones de esquema (índices y datos existentes)
+	if migrateOnly || cfg.MigrateOnStart {
+		host, _ := os.Hostname()
+		if _, err := migrations.Run(context.Background(), db, lockRepo, fmt.Sprintf("%s-%d", host, os.Getpid())); err != nil {
+			log.Fatalf("Error aplicando migraciones: %v", err)
+		}
+		if migrateOnly {
+			return nil
+		}
+	}
+
+	return &storage{
+		orders:    repository.NewMongoOrderRepository(db),
+		catalog:   repository.NewMongoStateCatalogRepository(db),
+		schedules: repository.NewMongoScheduleRepository(db),
+		locks:     lockRepo,
+		files:     repository.NewMongoFileStore(db),
+	}
+}
+
+// openMemory arma los repositorios en memoria (desarrollo local, sin Mongo)
+func openMemory() *storage {
+	return &storage{
+		orders:    repository.NewMemoryOrderRepository(),
+		catalog:   repository.NewMemoryStateCatalogRepository(),
+		schedules: repository.NewMemoryScheduleRepository(),
+		locks:     repository.NewMemoryLockRepository(),
+		files:     repository.NewMemoryFileStore(),
+	}
+}
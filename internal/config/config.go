@@ -1,7 +1,12 @@
 // config.go
 package config
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
 	MongoURI    string
@@ -10,6 +15,36 @@ type Config struct {
 	RabbitURL   string
 	OrdersURL   string
 	Port        string
+
+	// Dónde se guardan las órdenes: "mongo" o "memory" (desarrollo local, sin Mongo)
+	Storage string
+
+	// Archivo YAML/JSON con la definición del workflow de estados
+	WorkflowFile string
+
+	// Cada cuánto se revisan las reglas de SLA (timeouts por estado)
+	SLASchedulerInterval time.Duration
+
+	// Cada cuánto se aplican las transiciones programadas vencidas
+	ScheduleWorkerInterval time.Duration
+
+	// Plazo para que un admin revierta la última transición de una orden
+	RevertWindow time.Duration
+
+	// Plazo para que el dueño pida una devolución después de la entrega
+	ReturnWindow time.Duration
+
+	// Órdenes procesadas en paralelo por /admin/orders/bulk-status
+	BulkConcurrency int
+
+	// Tamaño máximo (bytes) de cada archivo de una constancia de entrega
+	ProofMaxFileSize int
+
+	// Intentos de entrega fallidos antes de devolver la orden al remitente
+	MaxDeliveryAttempts int
+
+	// Aplicar las migraciones de esquema pendientes al iniciar (si no, con -migrate)
+	MigrateOnStart bool
 }
 
 func Load() *Config {
@@ -20,12 +55,63 @@ func Load() *Config {
 		RabbitURL:   getEnv("RABBIT_URL", "amqp://host.docker.internal"),
 		OrdersURL:   getEnv("ORDERS_URL", "http://host.docker.internal:3004"),
 		Port:        getEnv("PORT", "8080"),
+		Storage:     getEnv("STORAGE", "mongo"),
+
+		WorkflowFile: getEnv("WORKFLOW_FILE", "workflow.yaml"),
+
+		SLASchedulerInterval:   getDuration("SLA_SCHEDULER_INTERVAL", time.Minute),
+		ScheduleWorkerInterval: getDuration("SCHEDULE_WORKER_INTERVAL", time.Minute),
+		RevertWindow:           getDuration("REVERT_WINDOW", 30*time.Minute),
+		ReturnWindow:           getDuration("RETURN_WINDOW", 30*24*time.Hour),
+		BulkConcurrency:        getInt("BULK_CONCURRENCY", 10),
+		ProofMaxFileSize:       getInt("PROOF_MAX_FILE_SIZE", 5<<20),
+		MaxDeliveryAttempts:    getInt("MAX_DELIVERY_ATTEMPTS", 3),
+		MigrateOnStart:         getBool("MIGRATE_ON_START", true),
 	}
 }
 
+func getInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("%s inválido (%q), se usa %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("%s inválido (%q), se usa %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
 	}
 	return fallback
 }
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("%s inválido (%q), se usa %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
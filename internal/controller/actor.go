@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actorFrom arma el actor con lo que dejó AuthMiddleware en el contexto
+func actorFrom(c *gin.Context) service.Actor {
+	return service.ActorFromPermissions(c.GetString("userID"), c.GetStringSlice("userPermissions"))
+}
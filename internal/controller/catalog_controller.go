@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Endpoints de administración del catálogo de estados (sólo admin)
+type CatalogController struct {
+	Service *service.StateCatalogService
+	Reasons *service.ReasonCodeCatalog
+}
+
+func NewCatalogController(s *service.StateCatalogService, reasons *service.ReasonCodeCatalog) *CatalogController {
+	return &CatalogController{Service: s, Reasons: reasons}
+}
+
+// GET /admin/states?workflow=...
+func (ctl *CatalogController) GetStates(c *gin.Context) {
+	states, err := ctl.Service.GetStates(c.Request.Context(), c.Query("workflow"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, states)
+}
+
+// POST /admin/states
+func (ctl *CatalogController) CreateState(c *gin.Context) {
+	var req dto.CreateStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	st, err := ctl.Service.CreateState(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(catalogErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, st)
+}
+
+// PUT /admin/states/:name?workflow=...
+func (ctl *CatalogController) UpdateState(c *gin.Context) {
+	var req dto.UpdateStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	st, err := ctl.Service.UpdateState(c.Request.Context(), c.Query("workflow"), c.Param("name"), req)
+	if err != nil {
+		c.JSON(catalogErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// DELETE /admin/states/:name?workflow=...
+func (ctl *CatalogController) DeleteState(c *gin.Context) {
+	if err := ctl.Service.DeleteState(c.Request.Context(), c.Query("workflow"), c.Param("name")); err != nil {
+		c.JSON(catalogErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "state deleted"})
+}
+
+// GET /admin/transitions?workflow=...
+func (ctl *CatalogController) GetTransitions(c *gin.Context) {
+	out, err := ctl.Service.GetTransitions(c.Request.Context(), c.Query("workflow"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// POST /admin/transitions
+func (ctl *CatalogController) AddTransition(c *gin.Context) {
+	var req dto.TransitionDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctl.Service.AddTransition(c.Request.Context(), req); err != nil {
+		c.JSON(catalogErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, req)
+}
+
+// DELETE /admin/transitions?workflow=...&from=...&to=...&role=...
+func (ctl *CatalogController) RemoveTransition(c *gin.Context) {
+	var req dto.TransitionDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctl.Service.RemoveTransition(c.Request.Context(), req); err != nil {
+		c.JSON(catalogErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "transition deleted"})
+}
+
+// GET /admin/reason-codes?status=...&lang=...
+// Sin lang devuelve todas las etiquetas; con lang agrega "label" en ese idioma.
+func (ctl *CatalogController) GetReasonCodes(c *gin.Context) {
+	codes := ctl.Reasons.List(c.Query("status"))
+
+	lang := c.Query("lang")
+	if lang == "" {
+		c.JSON(http.StatusOK, codes)
+		return
+	}
+
+	out := make([]gin.H, 0, len(codes))
+	for _, rc := range codes {
+		out = append(out, gin.H{
+			"code":      rc.Code,
+			"label":     ctl.Reasons.Label(rc.Code, lang),
+			"appliesTo": rc.AppliesTo,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func catalogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrStateNotFound), errors.Is(err, service.ErrTransitionNotFound),
+		errors.Is(err, service.ErrUnknownWorkflow):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrStateAlreadyExists), errors.Is(err, service.ErrTransitionExists),
+		errors.Is(err, service.ErrStateInUse), errors.Is(err, service.ErrInitialStateDelete),
+		errors.Is(err, service.ErrStateReferenced):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidState), errors.Is(err, service.ErrUnknownRole), errors.Is(err, service.ErrUnknownField),
+		errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrFinalStateHasOutput),
+		errors.Is(err, service.ErrStateUnreachable):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,11 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
-	"slices"
 
 	"order-status-service-2/internal/dto"
 	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/repository"
 	"order-status-service-2/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -31,14 +32,26 @@ func (ctl *OrderController) InitStatus(c *gin.Context) {
 		c.Request.Context(),
 		req.OrderID,
 		req.UserID,
+		req.Workflow,
 		req.Shipping,
+		req.Articles,
 		false, // ← No viene desde Rabbit, viene desde la API
 	)
+	if errors.Is(err, service.ErrUnknownWorkflow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// ErrDuplicateOrder: otra inicialización simultánea la creó primero
+	if errors.Is(err, service.ErrOrderAlreadyExists) || errors.Is(err, repository.ErrDuplicateOrder) {
+		c.JSON(http.StatusConflict, gin.H{"error": service.ErrOrderAlreadyExists.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.Header("ETag", etag(res.Version))
 	c.JSON(http.StatusCreated, res)
 }
 
@@ -52,18 +65,55 @@ func (ctl *OrderController) UpdateStatus(c *gin.Context) {
 		return
 	}
 
-	actorID := c.GetString("userID")
-	perms := c.GetStringSlice("userPermissions")
-	isAdmin := slices.Contains(perms, "admin")
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Version = version
 
-	err := ctl.Service.UpdateStatus(
+	err = ctl.Service.UpdateStatus(
 		c.Request.Context(),
 		orderID,
-		req.Status,
-		req.Reason,
-		actorID,
-		isAdmin,
+		req,
+		actorFrom(c),
 	)
+	// 412 sólo si falló la precondición del cliente (If-Match); una escritura
+	// concurrente sin If-Match es un conflicto común
+	if errors.Is(err, service.ErrStaleVersion) || (version != nil && errors.Is(err, repository.ErrVersionConflict)) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var missing *service.MissingFieldsError
+	if errors.As(err, &missing) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":         err.Error(),
+			"status":        missing.Status,
+			"missingFields": missing.Fields,
+		})
+		return
+	}
+	var notReady *service.ItemsNotReadyError
+	if errors.As(err, &notReady) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           err.Error(),
+			"status":          notReady.Status,
+			"pendingArticles": notReady.Articles,
+		})
+		return
+	}
+	var shipmentsPending *service.ShipmentsPendingError
+	if errors.As(err, &shipmentsPending) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":            err.Error(),
+			"pendingShipments": shipmentsPending.Shipments,
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -72,58 +122,360 @@ func (ctl *OrderController) UpdateStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "status updated"})
 }
 
-// GET /orders/mine - user (middleware debe poner userID)
-func (ctl *OrderController) GetMyOrders(c *gin.Context) {
-	userID := c.GetString("userID")
-	orders, err := ctl.Service.GetByUserID(c.Request.Context(), userID)
+// POST /admin/orders/bulk-status — admin y depósito
+func (ctl *OrderController) BulkUpdateStatus(c *gin.Context) {
+	var req dto.BulkStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := ctl.Service.BulkUpdateStatus(c.Request.Context(), req, actorFrom(c))
+	if errors.Is(err, service.ErrBulkNoTargets) || errors.Is(err, service.ErrBulkTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, orders)
+
+	res := dto.BulkStatusResponse{DryRun: req.DryRun, Total: len(results), Results: make([]dto.BulkStatusResult, 0, len(results))}
+	for _, r := range results {
+		item := dto.BulkStatusResult{OrderID: r.OrderID, Result: bulkResult(r.Err)}
+		if r.Err != nil {
+			item.Error = r.Err.Error()
+			res.Failed++
+		} else {
+			res.OK++
+		}
+		res.Results = append(res.Results, item)
+	}
+	c.JSON(http.StatusOK, res)
 }
 
-// GET /admin/orders - admin only (middleware AdminOnly)
-func (ctl *OrderController) GetAllOrders(c *gin.Context) {
-	orders, err := ctl.Service.GetAll(c.Request.Context())
-	if err != nil {
+// bulkResult clasifica el error de una orden en la respuesta de bulk-status
+func bulkResult(err error) string {
+	var missing *service.MissingFieldsError
+	var notReady *service.ItemsNotReadyError
+	var shipmentsPending *service.ShipmentsPendingError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, repository.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, service.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, repository.ErrVersionConflict), errors.Is(err, service.ErrStaleVersion):
+		return "conflict"
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrFinalState),
+		errors.Is(err, service.ErrResumeTarget), errors.Is(err, service.ErrInvalidReasonCode),
+		errors.As(err, &missing), errors.As(err, &notReady), errors.As(err, &shipmentsPending):
+		return "invalid_transition"
+	default:
+		return "error"
+	}
+}
+
+// PATCH /admin/orders/:orderId/items/:articleId/status — admin y depósito
+func (ctl *OrderController) UpdateItemStatus(c *gin.Context) {
+	var req dto.UpdateItemStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctl.Service.UpdateItemStatus(
+		c.Request.Context(),
+		c.Param("orderId"),
+		c.Param("articleId"),
+		req,
+		c.GetString("userID"),
+	)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "item status updated"})
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// POST /admin/orders/:orderId/shipments — admin y depósito
+func (ctl *OrderController) CreateShipment(c *gin.Context) {
+	var req dto.CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shipment, err := ctl.Service.CreateShipment(c.Request.Context(), c.Param("orderId"), req, c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, shipment)
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict), errors.Is(err, service.ErrArticleNotPacked),
+		errors.Is(err, service.ErrShipmentOrderStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// PATCH /admin/orders/:orderId/shipments/:shipmentId/status — admin, depósito y repartidores
+func (ctl *OrderController) UpdateShipmentStatus(c *gin.Context) {
+	var req dto.UpdateShipmentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctl.Service.UpdateShipmentStatus(
+		c.Request.Context(),
+		c.Param("orderId"),
+		c.Param("shipmentId"),
+		req,
+		actorFrom(c),
+	)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "shipment status updated"})
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrShipmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "the order is not assigned to you"})
+	case errors.Is(err, repository.ErrVersionConflict), errors.Is(err, service.ErrShipmentOrderStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOrderNotDelivered):
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// PUT /admin/orders/:orderId/courier — admin only
+func (ctl *OrderController) AssignCourier(c *gin.Context) {
+	var req dto.AssignCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, orders)
+
+	err := ctl.Service.AssignCourier(c.Request.Context(), c.Param("orderId"), req.CourierID, req.Reason, c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "courier assigned"})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, repository.ErrVersionConflict), errors.Is(err, service.ErrCourierAssignStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
 }
 
-// GET /admin/orders/state/:state - admin only
-func (ctl *OrderController) GetAllOrdersByState(c *gin.Context) {
-	state := c.Param("state")
-	orders, err := ctl.Service.GetByStatus(c.Request.Context(), state)
+// GET /courier/orders — órdenes asignadas al repartidor autenticado (paginado)
+func (ctl *OrderController) GetCourierOrders(c *gin.Context) {
+	q, err := orderQueryFrom(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, orders)
+	q.UserID = ""
+	page, err := ctl.Service.GetCourierOrders(c.Request.Context(), q, c.Query("next"), actorFrom(c))
+	writePage(c, page, err)
 }
 
-func (ctl *OrderController) GetLatestStatus(c *gin.Context) {
-	orderID := c.Param("orderId")
-	actorID := c.GetString("userID")
-	perms := c.GetStringSlice("userPermissions")
-	isAdmin := slices.Contains(perms, "admin")
+// POST /orders/:orderId/delivery-attempts — admin o repartidor asignado
+func (ctl *OrderController) RecordDeliveryAttempt(c *gin.Context) {
+	var req dto.DeliveryAttemptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempt, status, err := ctl.Service.RecordDeliveryAttempt(c.Request.Context(), c.Param("orderId"), req, actorFrom(c))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, dto.DeliveryAttemptResponse{Attempt: *attempt, Status: status})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "the order is not assigned to you"})
+	case errors.Is(err, service.ErrAttemptStatus), errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// POST /orders/:orderId/returns — sólo el dueño de la orden
+func (ctl *OrderController) RequestReturn(c *gin.Context) {
+	var req dto.CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ret, err := ctl.Service.RequestReturn(c.Request.Context(), c.Param("orderId"), req, c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, ret)
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot return another user's order"})
+	case errors.Is(err, service.ErrReturnNotAllowed), errors.Is(err, service.ErrReturnWindowExpired),
+		errors.Is(err, service.ErrReturnAlreadyOpen), errors.Is(err, service.ErrReturnArticleNotDelivered):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// PATCH /admin/orders/:orderId/returns/:returnId/status — admin only
+func (ctl *OrderController) UpdateReturnStatus(c *gin.Context) {
+	var req dto.UpdateReturnStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctl.Service.UpdateReturnStatus(
+		c.Request.Context(),
+		c.Param("orderId"),
+		c.Param("returnId"),
+		req,
+		c.GetString("userID"),
+	)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "return status updated"})
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrReturnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
 
-	// 1. Buscar orden
-	o, err := ctl.Service.GetByOrderID(c.Request.Context(), orderID)
+// GET /admin/returns?status= — admin only (por defecto, devoluciones solicitadas).
+// Acá status es el estado de la devolución; el resto de los filtros son los de cualquier listado.
+func (ctl *OrderController) GetOrdersWithReturns(c *gin.Context) {
+	q, err := orderQueryFrom(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.Statuses = nil
+	q.ReturnStatus = c.Query("status")
+	page, err := ctl.Service.GetByReturnStatus(c.Request.Context(), q, c.Query("next"))
+	writePage(c, page, err)
+}
+
+// POST /admin/orders/:orderId/resume — admin y soporte
+func (ctl *OrderController) ResumeOrder(c *gin.Context) {
+	var req dto.ResumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ctl.Service.ResumeOrder(c.Request.Context(), c.Param("orderId"), req.Reason, actorFrom(c))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "order resumed"})
+	case errors.Is(err, repository.ErrNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrNotOnHold):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// POST /admin/orders/:orderId/revert — admin only
+func (ctl *OrderController) RevertLastTransition(c *gin.Context) {
+	orderID := c.Param("orderId")
+
+	var req dto.RevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 2. Validación de acceso
-	if !isAdmin && o.UserID != actorID {
+	err := ctl.Service.RevertLastTransition(c.Request.Context(), orderID, req.Justification, c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "last transition reverted"})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrRevertWindowExpired), errors.Is(err, service.ErrRevertCustomerNotified),
+		errors.Is(err, service.ErrNothingToRevert), errors.Is(err, service.ErrRevertTargetUnavailable),
+		errors.Is(err, service.ErrRevertShipped):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrJustificationRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GET /orders/mine - user (middleware debe poner userID), paginado
+func (ctl *OrderController) GetMyOrders(c *gin.Context) {
+	q, err := orderQueryFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.UserID = c.GetString("userID")
+	page, err := ctl.Service.ListOrders(c.Request.Context(), q, c.Query("next"))
+	writePage(c, page, err)
+}
+
+// GET /admin/orders/all - admin, soporte y depósito, paginado y con filtros
+func (ctl *OrderController) GetAllOrders(c *gin.Context) {
+	q, err := orderQueryFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := ctl.Service.ListOrders(c.Request.Context(), q, c.Query("next"))
+	writePage(c, page, err)
+}
+
+// GET /admin/orders/:state - admin, soporte y depósito, paginado y con filtros
+func (ctl *OrderController) GetAllOrdersByState(c *gin.Context) {
+	q, err := orderQueryFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.Statuses = []string{c.Param("state")}
+	page, err := ctl.Service.ListOrders(c.Request.Context(), q, c.Query("next"))
+	writePage(c, page, err)
+}
+
+func (ctl *OrderController) GetLatestStatus(c *gin.Context) {
+	orderID := c.Param("orderId")
+
+	// 1. Buscar orden (el dueño o un rol con lectura de todas las órdenes)
+	o, err := ctl.Service.GetOrderFor(c.Request.Context(), orderID, actorFrom(c))
+	if errors.Is(err, service.ErrForbidden) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot view another user's order"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
 
-	// 3. Obtener estado actual
+	// 2. Obtener estado actual
 	var last *model.StatusRecord
 	for _, h := range o.History {
 		if h.Current {
@@ -137,19 +489,43 @@ func (ctl *OrderController) GetLatestStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, last)
+	c.Header("ETag", etag(o.Version))
+	c.JSON(http.StatusOK, dto.LatestStatusResponse{
+		StatusRecord: *last,
+		Shipments:    o.Shipments,
+		Returns:      o.Returns,
+		Attempts:     o.Attempts,
+	})
 }
 
+// GET /admin/orders/search?q=&limit=&next= - admin, soporte y depósito.
+// Busca por prefijo del ID de la orden y por texto en el usuario y los datos de envío.
+func (ctl *OrderController) SearchOrders(c *gin.Context) {
+	limit, err := limitFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := ctl.Service.SearchOrders(c.Request.Context(), c.Query("q"), limit, c.Query("next"))
+	writePage(c, page, err)
+}
+
+// GET /admin/orders-with-status - resumen de cada orden, paginado y con filtros
 func (ctl *OrderController) GetAllOrdersWithLatest(c *gin.Context) {
-	orders, err := ctl.Service.GetAll(c.Request.Context())
+	q, err := orderQueryFrom(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := ctl.Service.ListOrders(c.Request.Context(), q, c.Query("next"))
+	if err != nil {
+		writePage(c, nil, err)
 		return
 	}
 
-	var out []gin.H
+	out := []gin.H{}
 
-	for _, o := range orders {
+	for _, o := range page.Orders {
 		var current string
 		for _, h := range o.History {
 			if h.Current {
@@ -166,5 +542,9 @@ func (ctl *OrderController) GetAllOrdersWithLatest(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, out)
+	res := gin.H{"orders": out}
+	if page.Next != "" {
+		res["next"] = page.Next
+	}
+	c.JSON(http.StatusOK, res)
 }
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag arma el ETag de una orden a partir de su versión
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion lee la cabecera If-Match. Devuelve nil si no vino o es "*".
+func ifMatchVersion(c *gin.Context) (*int64, error) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return nil, nil
+	}
+	h = strings.Trim(strings.TrimPrefix(h, "W/"), `"`)
+	v, err := strconv.ParseInt(h, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("If-Match inválido: %q", c.GetHeader("If-Match"))
+	}
+	return &v, nil
+}
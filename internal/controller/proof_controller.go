@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /orders/:orderId/proof-of-delivery — multipart: recipientName, recipientDocument,
+// reason, signature (archivo, obligatorio) y photo (archivo, opcional).
+// Pasa la orden a "Entregado"; los permisos son los de esa transición.
+func (ctl *OrderController) DeliverWithProof(c *gin.Context) {
+	var uploads []service.ProofUpload
+	for _, kind := range []string{service.ProofSignature, service.ProofPhoto} {
+		fh, err := c.FormFile(kind)
+		if errors.Is(err, http.ErrMissingFile) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		uploads = append(uploads, service.ProofUpload{Kind: kind, Filename: fh.Filename, Size: fh.Size, Content: f})
+	}
+
+	proof, err := ctl.Service.DeliverWithProof(
+		c.Request.Context(),
+		c.Param("orderId"),
+		c.PostForm("recipientName"),
+		c.PostForm("recipientDocument"),
+		c.PostForm("reason"),
+		uploads,
+		actorFrom(c),
+	)
+	var missing *service.MissingFieldsError
+	var shipmentsPending *service.ShipmentsPendingError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, proof)
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot deliver this order"})
+	case errors.Is(err, service.ErrProofFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProofFileType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &missing):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":         err.Error(),
+			"status":        missing.Status,
+			"missingFields": missing.Fields,
+		})
+	case errors.As(err, &shipmentsPending):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":            err.Error(),
+			"pendingShipments": shipmentsPending.Shipments,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// GET /orders/:orderId/proof-of-delivery/:fileId — dueño de la orden o admin
+func (ctl *OrderController) DownloadProofFile(c *gin.Context) {
+	file, content, err := ctl.Service.OpenProofFile(c.Request.Context(), c.Param("orderId"), c.Param("fileId"), actorFrom(c))
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot view another user's order"})
+		return
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrFileNotFound),
+		errors.Is(err, service.ErrProofFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename})
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, content, map[string]string{
+		"Content-Disposition": disposition,
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderQueryFrom arma los filtros, el orden y el tamaño de página de un listado:
+// ?status=A,B&workflow=&userId=&province=&createdFrom=&createdTo=&updatedFrom=&updatedTo=
+// &sort=created_at|updated_at&order=asc|desc&limit= (fechas en RFC 3339).
+// El token de la página siguiente viene aparte, en ?next=.
+func orderQueryFrom(c *gin.Context) (model.OrderQuery, error) {
+	q := model.OrderQuery{
+		Workflow: c.Query("workflow"),
+		UserID:   c.Query("userId"),
+		Province: c.Query("province"),
+		SortBy:   c.Query("sort"),
+	}
+
+	for _, v := range c.QueryArray("status") {
+		for _, st := range strings.Split(v, ",") {
+			if st = strings.TrimSpace(st); st != "" {
+				q.Statuses = append(q.Statuses, st)
+			}
+		}
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		q.Desc = true
+	case "asc":
+	default:
+		return q, errors.New("order debe ser asc o desc")
+	}
+
+	limit, err := limitFrom(c)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = limit
+
+	dates := []struct {
+		param string
+		dst   **time.Time
+	}{
+		{"createdFrom", &q.CreatedFrom},
+		{"createdTo", &q.CreatedTo},
+		{"updatedFrom", &q.UpdatedFrom},
+		{"updatedTo", &q.UpdatedTo},
+	}
+	for _, d := range dates {
+		v := c.Query(d.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("%s debe ser una fecha RFC 3339", d.param)
+		}
+		*d.dst = &t
+	}
+	return q, nil
+}
+
+// limitFrom lee ?limit= (0 si no viene: el servicio usa el tamaño por defecto)
+func limitFrom(c *gin.Context) (int, error) {
+	v := c.Query("limit")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, errors.New("limit debe ser un entero positivo")
+	}
+	return n, nil
+}
+
+// writePage responde una página de órdenes (o el error del listado)
+func writePage(c *gin.Context, page *service.OrderPage, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, page)
+	case errors.Is(err, service.ErrInvalidSort), errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrEmptySearch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleController struct {
+	Scheduler *service.TransitionScheduler
+}
+
+func NewScheduleController(s *service.TransitionScheduler) *ScheduleController {
+	return &ScheduleController{Scheduler: s}
+}
+
+// POST /admin/orders/:orderId/scheduled-transitions — admin only
+func (ctl *ScheduleController) Schedule(c *gin.Context) {
+	var req dto.ScheduleTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	st, err := ctl.Scheduler.Schedule(c.Request.Context(), c.Param("orderId"), req, c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, st)
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// GET /admin/scheduled-transitions?orderId= — admin only
+// (no cuelga de /admin/orders/:orderId porque choca con GET /admin/orders/:state)
+func (ctl *ScheduleController) List(c *gin.Context) {
+	orderID := c.Query("orderId")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "orderId is required"})
+		return
+	}
+
+	list, err := ctl.Scheduler.List(c.Request.Context(), orderID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, list)
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// DELETE /admin/orders/:orderId/scheduled-transitions/:scheduleId — admin only
+func (ctl *ScheduleController) Cancel(c *gin.Context) {
+	err := ctl.Scheduler.Cancel(c.Request.Context(), c.Param("orderId"), c.Param("scheduleId"), c.GetString("userID"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "scheduled transition cancelled"})
+	case errors.Is(err, repository.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrScheduleNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
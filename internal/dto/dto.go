@@ -1,13 +1,57 @@
 // dto.go
 package dto
 
-import "time"
+import (
+	"time"
+
+	"order-status-service-2/internal/model"
+)
 
 // CreateOrderStatusRequest usado por la API y Rabbit para inicializar una orden
 type InitOrderStatusRequest struct {
-	OrderID  string      `json:"orderId" binding:"required"`
-	UserID   string      `json:"userId" binding:"required"`
-	Shipping ShippingDTO `json:"shipping"`
+	OrderID  string       `json:"orderId" binding:"required"`
+	UserID   string       `json:"userId" binding:"required"`
+	Workflow string       `json:"workflow"` // vacío = home_delivery
+	Shipping ShippingDTO  `json:"shipping"`
+	Articles []ArticleDTO `json:"articles"`
+}
+
+// ArticleDTO artículo de la orden, tal como llega de Order
+type ArticleDTO struct {
+	ArticleID string `json:"articleId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// UpdateItemStatusRequest usado por /admin/orders/:orderId/items/:articleId/status
+type UpdateItemStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// CreateShipmentRequest usado por /admin/orders/:orderId/shipments para despachar un paquete
+type CreateShipmentRequest struct {
+	Articles       []string `json:"articles" binding:"required,min=1"`
+	Carrier        string   `json:"carrier" binding:"required"`
+	TrackingNumber string   `json:"trackingNumber" binding:"required"`
+	Reason         string   `json:"reason"`
+}
+
+// UpdateShipmentStatusRequest usado por /admin/orders/:orderId/shipments/:shipmentId/status
+type UpdateShipmentStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// CreateReturnRequest usado por /orders/:orderId/returns para pedir una devolución
+type CreateReturnRequest struct {
+	Reason   string   `json:"reason" binding:"required"`
+	Articles []string `json:"articles"` // vacío = toda la orden
+}
+
+// UpdateReturnStatusRequest usado por /admin/orders/:orderId/returns/:returnId/status
+type UpdateReturnStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
 }
 
 // ShippingDTO para la dirección y comentario
@@ -21,7 +65,89 @@ type ShippingDTO struct {
 }
 
 type UpdateStatusRequest struct {
-	Status string `json:"status" binding:"required"`
+	Status string            `json:"status" binding:"required"`
+	Reason string            `json:"reason"`
+	Data   TransitionDataDTO `json:"data"`
+
+	// Versión esperada de la orden (cabecera If-Match); nil = no se controla
+	Version *int64 `json:"-"`
+}
+
+// TransitionDataDTO datos que algunos estados exigen para poder entrar en ellos
+type TransitionDataDTO struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"trackingNumber"`
+	ReasonCode     string `json:"reasonCode"`
+
+	// Constancia de entrega: sólo la completa POST /orders/:orderId/proof-of-delivery
+	Proof *model.ProofOfDelivery `json:"-"`
+}
+
+// BulkStatusRequest usado por /admin/orders/bulk-status: la misma transición
+// para una lista de órdenes y/o las que cumplan el filtro
+type BulkStatusRequest struct {
+	OrderIDs []string          `json:"orderIds"`
+	Filter   *BulkFilterDTO    `json:"filter"`
+	Status   string            `json:"status" binding:"required"`
+	Reason   string            `json:"reason"`
+	Data     TransitionDataDTO `json:"data"`
+	DryRun   bool              `json:"dryRun"` // sólo valida, no cambia nada
+}
+
+type BulkFilterDTO struct {
+	Status   string `json:"status"`
+	Workflow string `json:"workflow"` // vacío = todos
+}
+
+// BulkStatusResponse resultado por orden de /admin/orders/bulk-status
+type BulkStatusResponse struct {
+	DryRun  bool               `json:"dryRun"`
+	Total   int                `json:"total"`
+	OK      int                `json:"ok"`
+	Failed  int                `json:"failed"`
+	Results []BulkStatusResult `json:"results"`
+}
+
+type BulkStatusResult struct {
+	OrderID string `json:"orderId"`
+	Result  string `json:"result"` // ok, not_found, invalid_transition, forbidden, conflict, error
+	Error   string `json:"error,omitempty"`
+}
+
+// ScheduleTransitionRequest usado por /admin/orders/:orderId/scheduled-transitions
+type ScheduleTransitionRequest struct {
+	Status string            `json:"status" binding:"required"`
+	Reason string            `json:"reason"`
+	Data   TransitionDataDTO `json:"data"`
+	DueAt  time.Time         `json:"dueAt" binding:"required"` // RFC 3339, ej: 2026-10-18T08:00:00-03:00
+}
+
+// RevertRequest usado por /admin/orders/:orderId/revert
+type RevertRequest struct {
+	Justification string `json:"justification" binding:"required"`
+}
+
+// AssignCourierRequest usado por /admin/orders/:orderId/courier
+type AssignCourierRequest struct {
+	CourierID string `json:"courierId" binding:"required"`
+	Reason    string `json:"reason"`
+}
+
+// DeliveryAttemptRequest usado por /orders/:orderId/delivery-attempts
+type DeliveryAttemptRequest struct {
+	Reason        string     `json:"reason" binding:"required"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+}
+
+// DeliveryAttemptResponse el intento registrado y el estado en que quedó la orden
+// (si se llegó al máximo de intentos, "Devuelto al remitente")
+type DeliveryAttemptResponse struct {
+	Attempt model.DeliveryAttempt `json:"attempt"`
+	Status  string                `json:"status"`
+}
+
+// ResumeRequest usado por /admin/orders/:orderId/resume
+type ResumeRequest struct {
 	Reason string `json:"reason"`
 }
 
@@ -33,3 +159,33 @@ type OrderStatusResponse struct {
 	CreatedAt time.Time   `json:"createdAt"`
 	UpdatedAt time.Time   `json:"updatedAt"`
 }
+
+// LatestStatusResponse usado por /orders/:orderId/latest: el registro actual,
+// el estado de cada paquete (si se despachó en varios) y las devoluciones
+type LatestStatusResponse struct {
+	model.StatusRecord
+	Shipments []model.Shipment        `json:"shipments,omitempty"`
+	Returns   []model.OrderReturn     `json:"returns,omitempty"`
+	Attempts  []model.DeliveryAttempt `json:"deliveryAttempts,omitempty"`
+}
+
+// CreateStateRequest usado por /admin/states para dar de alta un estado
+type CreateStateRequest struct {
+	Workflow string   `json:"workflow"` // vacío = home_delivery
+	Name     string   `json:"name" binding:"required"`
+	Final    bool     `json:"final"`
+	Requires []string `json:"requires"`
+}
+
+type UpdateStateRequest struct {
+	Final    bool     `json:"final"`
+	Requires []string `json:"requires"`
+}
+
+// TransitionDTO representa una arista del grafo de estados para un rol
+type TransitionDTO struct {
+	Workflow string `json:"workflow" form:"workflow"` // vacío = home_delivery
+	From     string `json:"from" form:"from" binding:"required"`
+	To       string `json:"to" form:"to" binding:"required"`
+	Role     string `json:"role" form:"role" binding:"required"`
+}
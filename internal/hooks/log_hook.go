@@ -0,0 +1,25 @@
+// log_hook.go
+package hooks
+
+import (
+	"context"
+	"log"
+
+	"order-status-service-2/internal/service"
+)
+
+// LogHook deja registro en logs de cada cambio de estado.
+type LogHook struct{}
+
+func NewLogHook() *LogHook {
+	return &LogHook{}
+}
+
+func (h *LogHook) Name() string {
+	return "log"
+}
+
+func (h *LogHook) OnTransition(ctx context.Context, ev service.TransitionEvent) error {
+	log.Printf("[Estado] Orden %s: %s → %s (actor %s)", ev.Order.OrderID, ev.From, ev.To, ev.ActorID)
+	return nil
+}
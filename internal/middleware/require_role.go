@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"order-status-service-2/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole deja pasar sólo a quien tenga alguno de los roles indicados
+// (los roles salen de los permisos que devuelve el servicio de auth).
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := service.ActorFromPermissions(c.GetString("userID"), c.GetStringSlice("userPermissions"))
+		if !actor.HasAny(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Colecciones migradas (los nombres coinciden con los de repository)
+const (
+	ordersCollection    = "order_statuses"
+	schedulesCollection = "scheduled_transitions"
+	catalogCollection   = "state_catalog"
+)
+
+// All son las migraciones del servicio, en orden. Para cambiar el esquema se agrega
+// una nueva al final; nunca se modifica ni se borra una ya publicada.
+var All = []Migration{
+	{Version: 1, Name: "índice único de order_id", Up: uniqueOrderID},
+	{Version: 2, Name: "índices de los listados paginados", Up: listingIndexes},
+	{Version: 3, Name: "índice de texto para la búsqueda", Up: textSearchIndex},
+	{Version: 4, Name: "completar workflow, version y sla_since en órdenes viejas", Up: backfillOrders},
+	{Version: 5, Name: "índices de transiciones programadas", Up: scheduleIndexes},
+	{Version: 6, Name: "índice único de estados del catálogo", Up: uniqueCatalogState},
+}
+
+// uniqueOrderID evita que dos inicializaciones simultáneas creen la misma orden.
+// Reemplaza el índice común sobre order_id, si existe.
+func uniqueOrderID(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(ordersCollection)
+	if err := checkDuplicates(ctx, col, "order_id"); err != nil {
+		return err
+	}
+	if err := dropIndex(ctx, col, "order_id_1"); err != nil {
+		return err
+	}
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "order_id", Value: 1}},
+		Options: options.Index().SetName("order_id_unique").SetUnique(true),
+	})
+	return err
+}
+
+// listingIndexes cubre los listados paginados (FindPage): para cada campo de orden
+// y order_id, solo y precedido por cada filtro. Sirven en ambas direcciones.
+func listingIndexes(ctx context.Context, db *mongo.Database) error {
+	var models []mongo.IndexModel
+	for _, sortBy := range []string{"created_at", "updated_at"} {
+		models = append(models, mongo.IndexModel{Keys: bson.D{{Key: sortBy, Value: 1}, {Key: "order_id", Value: 1}}})
+		for _, field := range []string{"status", "user_id", "courier_id", "returns.status", "shipping.province"} {
+			models = append(models, mongo.IndexModel{
+				Keys: bson.D{{Key: field, Value: 1}, {Key: sortBy, Value: 1}, {Key: "order_id", Value: 1}},
+			})
+		}
+	}
+	// Reglas de SLA (FindStale)
+	models = append(models, mongo.IndexModel{
+		Keys: bson.D{{Key: "workflow", Value: 1}, {Key: "status", Value: 1}, {Key: "sla_since", Value: 1}},
+	})
+	_, err := db.Collection(ordersCollection).Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// textSearchIndex es el índice de Search; sólo puede haber uno de texto por colección
+func textSearchIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(ordersCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "order_id", Value: "text"},
+			{Key: "user_id", Value: "text"},
+			{Key: "shipping.address_line1", Value: "text"},
+			{Key: "shipping.city", Value: "text"},
+			{Key: "shipping.postal_code", Value: "text"},
+			{Key: "shipping.province", Value: "text"},
+			{Key: "shipping.country", Value: "text"},
+			{Key: "shipping.comments", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("orders_text").
+			SetDefaultLanguage("spanish").
+			SetWeights(bson.D{
+				{Key: "order_id", Value: 10},
+				{Key: "user_id", Value: 5},
+				{Key: "shipping.address_line1", Value: 3},
+				{Key: "shipping.city", Value: 3},
+			}),
+	})
+	return err
+}
+
+// backfillOrders completa los campos que no existían cuando se crearon las órdenes
+// viejas: workflow (home_delivery), version (0) y sla_since (su updated_at).
+func backfillOrders(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(ordersCollection)
+	updates := []struct {
+		filter bson.M
+		update interface{}
+	}{
+		{bson.M{"workflow": bson.M{"$in": bson.A{nil, ""}}}, bson.M{"$set": bson.M{"workflow": "home_delivery"}}},
+		{bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": int64(0)}}},
+		{bson.M{"sla_since": bson.M{"$exists": false}}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"sla_since": "$updated_at"}}},
+		}},
+	}
+	for _, u := range updates {
+		if _, err := col.UpdateMany(ctx, u.filter, u.update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleIndexes cubre el worker (pendientes vencidas) y el listado por orden
+func scheduleIndexes(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(schedulesCollection)
+	if err := checkDuplicates(ctx, col, "schedule_id"); err != nil {
+		return err
+	}
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "schedule_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "due_at", Value: 1}}},
+		{Keys: bson.D{{Key: "order_id", Value: 1}, {Key: "due_at", Value: 1}}},
+	})
+	return err
+}
+
+// uniqueCatalogState evita estados repetidos dentro de un workflow
+func uniqueCatalogState(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(catalogCollection)
+	if err := checkDuplicates(ctx, col, "workflow", "name"); err != nil {
+		return err
+	}
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "workflow", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// checkDuplicates falla si hay documentos repetidos para los campos indicados,
+// con algunos ejemplos: un índice único no se puede crear hasta resolverlos a mano.
+func checkDuplicates(ctx context.Context, col *mongo.Collection, fields ...string) error {
+	key := bson.M{}
+	for _, f := range fields {
+		key[f] = "$" + f
+	}
+	cur, err := col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": key, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 10}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var dups []string
+	for cur.Next(ctx) {
+		var g struct {
+			ID    bson.M `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cur.Decode(&g); err != nil {
+			return err
+		}
+		dups = append(dups, fmt.Sprintf("%v (%d)", g.ID, g.Count))
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if len(dups) > 0 {
+		return fmt.Errorf("hay documentos repetidos en %s por %s: %s",
+			col.Name(), strings.Join(fields, ", "), strings.Join(dups, "; "))
+	}
+	return nil
+}
+
+// dropIndex borra el índice si existe
+func dropIndex(ctx context.Context, col *mongo.Collection, name string) error {
+	_, err := col.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26) { // IndexNotFound, NamespaceNotFound
+		return nil
+	}
+	return err
+}
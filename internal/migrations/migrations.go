@@ -0,0 +1,129 @@
+// Package migrations aplica cambios de esquema versionados sobre MongoDB: índices
+// y transformaciones de documentos existentes. Cada migración aplicada queda
+// registrada en la colección schema_migrations y no se vuelve a ejecutar.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration es un cambio de esquema. Version define el orden y no se puede reutilizar;
+// Up tiene que poder reintentarse si falló a la mitad (índices y updates idempotentes).
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// Registro de una migración aplicada (colección schema_migrations)
+type Applied struct {
+	Version    int       `bson:"_id" json:"version"`
+	Name       string    `bson:"name" json:"name"`
+	AppliedAt  time.Time `bson:"applied_at" json:"appliedAt"`
+	DurationMS int64     `bson:"duration_ms" json:"durationMs"`
+}
+
+// Locker evita que dos réplicas migren a la vez (lo implementa MongoLockRepository)
+type Locker interface {
+	Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, name, owner string) error
+}
+
+const (
+	lockName = "schema_migrations"
+	lockTTL  = 30 * time.Minute
+	lockWait = 2 * time.Second
+)
+
+// Run aplica, en orden, las migraciones que todavía no figuran en schema_migrations
+// y devuelve las que aplicó. Si otra réplica está migrando, espera a que termine.
+// Corta en la primera que falla: las siguientes quedan pendientes.
+func Run(ctx context.Context, db *mongo.Database, locks Locker, owner string) ([]Applied, error) {
+	return run(ctx, db, locks, owner, All)
+}
+
+func run(ctx context.Context, db *mongo.Database, locks Locker, owner string, all []Migration) ([]Applied, error) {
+	sorted := append([]Migration(nil), all...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("migración %d duplicada", sorted[i].Version)
+		}
+	}
+
+	for {
+		ok, err := locks.Acquire(ctx, lockName, owner, lockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		log.Printf("⏳ Otra réplica está aplicando migraciones, esperando...")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockWait):
+		}
+	}
+	defer func() {
+		if err := locks.Release(context.Background(), lockName, owner); err != nil {
+			log.Printf("❌ No se pudo liberar el lock de migraciones: %v", err)
+		}
+	}()
+
+	col := db.Collection("schema_migrations")
+	done, err := appliedVersions(ctx, col)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Applied
+	for _, m := range sorted {
+		if done[m.Version] {
+			continue
+		}
+		start := time.Now()
+		if err := m.Up(ctx, db); err != nil {
+			return applied, fmt.Errorf("migración %d (%s): %w", m.Version, m.Name, err)
+		}
+		rec := Applied{
+			Version:    m.Version,
+			Name:       m.Name,
+			AppliedAt:  time.Now().UTC(),
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if _, err := col.InsertOne(ctx, rec); err != nil {
+			return applied, fmt.Errorf("migración %d (%s): registrando: %w", m.Version, m.Name, err)
+		}
+		log.Printf("✅ Migración %d aplicada: %s (%d ms)", rec.Version, rec.Name, rec.DurationMS)
+		applied = append(applied, rec)
+	}
+	return applied, nil
+}
+
+func appliedVersions(ctx context.Context, col *mongo.Collection) (map[int]bool, error) {
+	cur, err := col.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	done := map[int]bool{}
+	for cur.Next(ctx) {
+		var rec Applied
+		if err := cur.Decode(&rec); err != nil {
+			return nil, err
+		}
+		done[rec.Version] = true
+	}
+	return done, cur.Err()
+}
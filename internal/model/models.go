@@ -3,14 +3,30 @@ package model
 
 import "time"
 
+// Workflow asignado a las órdenes que no indican tipo de entrega
+// (y a las creadas antes de que existieran varios workflows).
+const DefaultWorkflow = "home_delivery"
+
+// Workflow de los productos digitales: no hay envío, así que la orden no lleva dirección.
+const DigitalWorkflow = "digital"
+
 type OrderStatus struct {
-	OrderID   string         `bson:"order_id" json:"orderId"`
-	UserID    string         `bson:"user_id" json:"userId"`
-	Status    string         `bson:"status" json:"status"` // estado actual
-	History   []StatusRecord `bson:"history" json:"history"`
-	Shipping  Shipping       `bson:"shipping" json:"shipping"`
-	CreatedAt time.Time      `bson:"created_at" json:"createdAt"`
-	UpdatedAt time.Time      `bson:"updated_at" json:"updatedAt"`
+	OrderID   string            `bson:"order_id" json:"orderId"`
+	UserID    string            `bson:"user_id" json:"userId"`
+	Workflow  string            `bson:"workflow" json:"workflow"` // tipo de entrega: home_delivery, store_pickup, digital
+	Status    string            `bson:"status" json:"status"`     // estado actual
+	History   []StatusRecord    `bson:"history" json:"history"`
+	Shipping  Shipping          `bson:"shipping" json:"shipping"`
+	Items     []LineItem        `bson:"items,omitempty" json:"items,omitempty"`                        // artículos, cada uno con su estado
+	Shipments []Shipment        `bson:"shipments,omitempty" json:"shipments,omitempty"`                // paquetes en los que se despachó la orden
+	Returns   []OrderReturn     `bson:"returns,omitempty" json:"returns,omitempty"`                    // devoluciones pedidas después de la entrega
+	CourierID string            `bson:"courier_id,omitempty" json:"courierId,omitempty"`               // repartidor asignado (órdenes en "Enviado")
+	Attempts  []DeliveryAttempt `bson:"delivery_attempts,omitempty" json:"deliveryAttempts,omitempty"` // intentos de entrega fallidos
+	Flags     []OrderFlag       `bson:"flags,omitempty" json:"flags,omitempty"`                        // alertas (ej: SLA vencido)
+	Version   int64             `bson:"version" json:"version"`                                        // aumenta con cada escritura (concurrencia optimista)
+	SLASince  time.Time         `bson:"sla_since" json:"-"`                                            // desde cuándo corre el SLA del estado actual (se pausa en espera)
+	CreatedAt time.Time         `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time         `bson:"updated_at" json:"updatedAt"`
 }
 
 type Shipping struct {
@@ -23,11 +39,207 @@ type Shipping struct {
 }
 
 type StatusRecord struct {
-	Status    string    `bson:"status" json:"status"`
-	Reason    string    `bson:"reason" json:"reason"`
-	UserID    string    `bson:"user" json:"userId"`
-	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	Status     string    `bson:"status" json:"status"`
+	Reason     string    `bson:"reason" json:"reason"`
+	ReasonCode string    `bson:"reason_code,omitempty" json:"reasonCode,omitempty"` // código del catálogo de motivos
+	UserID     string    `bson:"user" json:"userId"`
+	Timestamp  time.Time `bson:"timestamp" json:"timestamp"`
+
+	// Datos exigidos por la transición (transportista, tracking)
+	Data *TransitionData `bson:"data,omitempty" json:"data,omitempty"`
+
+	// Registro compensatorio: deshace la transición a RevertedFrom (Reason = justificación)
+	Revert       bool   `bson:"revert,omitempty" json:"revert,omitempty"`
+	RevertedFrom string `bson:"reverted_from,omitempty" json:"revertedFrom,omitempty"`
+
+	// Sólo en registros "En Espera": estado al que vuelve la orden al reanudarse
+	HeldFrom string `bson:"held_from,omitempty" json:"heldFrom,omitempty"`
+
+	// Sólo en registros de asignación: repartidor asignado (el estado no cambia)
+	CourierID string `bson:"courier_id,omitempty" json:"courierId,omitempty"`
+
+	// Inicio del SLA que quedó al aplicar el registro (lo usa la reversión para restaurarlo)
+	SLASince time.Time `bson:"sla_since,omitempty" json:"-"`
 
 	// Para marcar cuál es el último
 	Current bool `bson:"current" json:"current"`
 }
+
+// Artículo de la orden, con su propio estado e historial.
+// En el historial de la línea el estado actual es Status (no se usa Current).
+type LineItem struct {
+	ArticleID string         `bson:"article_id" json:"articleId"`
+	Quantity  int            `bson:"quantity" json:"quantity"`
+	Status    string         `bson:"status" json:"status"`
+	History   []StatusRecord `bson:"history" json:"history"`
+}
+
+// Paquete de una orden despachada en varias partes, con su propio historial
+// (Enviado → Entregado). Como en LineItem, el estado actual es Status.
+type Shipment struct {
+	ShipmentID     string         `bson:"shipment_id" json:"shipmentId"`
+	Articles       []string       `bson:"articles" json:"articles"` // article_id de las líneas incluidas
+	Carrier        string         `bson:"carrier" json:"carrier"`
+	TrackingNumber string         `bson:"tracking_number" json:"trackingNumber"`
+	Status         string         `bson:"status" json:"status"`
+	History        []StatusRecord `bson:"history" json:"history"`
+	CreatedAt      time.Time      `bson:"created_at" json:"createdAt"`
+}
+
+// Devolución pedida por el dueño sobre una orden ya entregada. Tiene su propio
+// historial, separado del de la orden (que queda en el estado de entrega).
+type OrderReturn struct {
+	ReturnID  string         `bson:"return_id" json:"returnId"`
+	Articles  []string       `bson:"articles,omitempty" json:"articles,omitempty"` // vacío = toda la orden
+	Reason    string         `bson:"reason" json:"reason"`
+	Status    string         `bson:"status" json:"status"`
+	History   []StatusRecord `bson:"history" json:"history"`
+	CreatedAt time.Time      `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time      `bson:"updated_at" json:"updatedAt"`
+}
+
+// Estados de una transición programada
+const (
+	SchedulePending    = "pending"
+	ScheduleProcessing = "processing" // la tomó el worker
+	ScheduleApplied    = "applied"
+	ScheduleSkipped    = "skipped" // al vencer ya no era válida
+	ScheduleCancelled  = "cancelled"
+)
+
+// Transición programada por un admin para una fecha futura (colección scheduled_transitions).
+// La aplica el worker a la hora indicada, volviendo a validarla en ese momento.
+type ScheduledTransition struct {
+	ScheduleID  string          `bson:"schedule_id" json:"scheduleId"`
+	OrderID     string          `bson:"order_id" json:"orderId"`
+	FromStatus  string          `bson:"from_status" json:"fromStatus"` // estado de la orden al programarla
+	Status      string          `bson:"status" json:"status"`          // estado destino
+	Reason      string          `bson:"reason" json:"reason"`
+	ReasonCode  string          `bson:"reason_code,omitempty" json:"reasonCode,omitempty"`
+	Data        *TransitionData `bson:"data,omitempty" json:"data,omitempty"`
+	DueAt       time.Time       `bson:"due_at" json:"dueAt"`
+	State       string          `bson:"state" json:"state"`                       // pending, processing, applied, skipped, cancelled
+	Result      string          `bson:"result,omitempty" json:"result,omitempty"` // por qué se omitió o canceló
+	CreatedBy   string          `bson:"created_by" json:"createdBy"`
+	CreatedAt   time.Time       `bson:"created_at" json:"createdAt"`
+	ProcessedAt *time.Time      `bson:"processed_at,omitempty" json:"processedAt,omitempty"`
+	ClaimedAt   *time.Time      `bson:"claimed_at,omitempty" json:"claimedAt,omitempty"` // cuándo la tomó el worker (processing)
+}
+
+// Intento de entrega fallido (nadie en el domicilio, dirección incorrecta...).
+// No cambia el estado de la orden; al llegar al máximo, vuelve al remitente.
+type DeliveryAttempt struct {
+	Number        int        `bson:"number" json:"number"` // 1, 2, 3...
+	Reason        string     `bson:"reason" json:"reason"`
+	NextAttemptAt *time.Time `bson:"next_attempt_at,omitempty" json:"nextAttemptAt,omitempty"`
+	UserID        string     `bson:"user" json:"userId"` // quien lo registró (normalmente el repartidor)
+	Timestamp     time.Time  `bson:"timestamp" json:"timestamp"`
+}
+
+// Alerta sobre una orden que no cambia su estado (ej: "Enviado" hace más de 15 días)
+type OrderFlag struct {
+	Code      string    `bson:"code" json:"code"`     // ej: SLA_EXPIRED
+	Status    string    `bson:"status" json:"status"` // estado en el que estaba al marcarse
+	Reason    string    `bson:"reason" json:"reason"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+type TransitionData struct {
+	Carrier        string           `bson:"carrier,omitempty" json:"carrier,omitempty"`
+	TrackingNumber string           `bson:"tracking_number,omitempty" json:"trackingNumber,omitempty"`
+	Proof          *ProofOfDelivery `bson:"proof,omitempty" json:"proof,omitempty"` // sólo en la entrega
+}
+
+// Constancia de entrega: quién recibió la orden y los archivos (firma, foto)
+// guardados en GridFS (bucket delivery_proofs).
+type ProofOfDelivery struct {
+	RecipientName     string         `bson:"recipient_name" json:"recipientName"`
+	RecipientDocument string         `bson:"recipient_document" json:"recipientDocument"`
+	Files             []DeliveryFile `bson:"files" json:"files"`
+}
+
+type DeliveryFile struct {
+	FileID      string `bson:"file_id" json:"fileId"` // id del archivo en GridFS
+	Kind        string `bson:"kind" json:"kind"`      // signature, photo
+	Filename    string `bson:"filename" json:"filename"`
+	ContentType string `bson:"content_type" json:"contentType"`
+	Size        int64  `bson:"size" json:"size"`
+}
+
+// Motivo codificado de cancelaciones y rechazos (sección reasonCodes de workflow.yaml)
+type ReasonCode struct {
+	Code      string            `json:"code"`
+	Labels    map[string]string `json:"labels"`    // idioma -> texto (es, en)
+	AppliesTo []string          `json:"appliesTo"` // estados destino en los que se puede usar
+}
+
+// Estado del catálogo administrable (colección state_catalog).
+// Cada estado pertenece a un workflow; Transitions indica, por rol,
+// a qué estados de ese mismo workflow se puede pasar desde este.
+type CatalogState struct {
+	Workflow         string              `bson:"workflow" json:"workflow"`
+	Name             string              `bson:"name" json:"name"`
+	Initial          bool                `bson:"initial" json:"initial"`
+	Final            bool                `bson:"final" json:"final"`
+	NotifiesCustomer bool                `bson:"notifies_customer" json:"notifiesCustomer"` // al entrar se notifica al cliente (no se puede revertir)
+	Returnable       bool                `bson:"returnable" json:"returnable"`              // desde este estado el cliente puede pedir una devolución
+	Requires         []string            `bson:"requires" json:"requires"`                  // datos exigidos para entrar al estado
+	ItemsRequire     []string            `bson:"items_require" json:"itemsRequire"`         // estados de línea exigidos para entrar al estado
+	Timeout          *StateTimeout       `bson:"timeout,omitempty" json:"timeout,omitempty"`
+	Transitions      map[string][]string `bson:"transitions" json:"transitions"`
+	RuntimeEdited    bool                `bson:"runtime_edited" json:"runtimeEdited"` // editado con la API: Seed no lo pisa
+	CreatedAt        time.Time           `bson:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time           `bson:"updated_at" json:"updatedAt"`
+}
+
+// Regla de SLA: qué hacer con una orden que lleva demasiado tiempo en el estado
+type StateTimeout struct {
+	After      string `bson:"after" json:"after"`                                // duración Go: "48h"
+	Action     string `bson:"action" json:"action"`                              // "transition" o "flag"
+	To         string `bson:"to,omitempty" json:"to,omitempty"`                  // estado destino (action = transition)
+	ReasonCode string `bson:"reason_code,omitempty" json:"reasonCode,omitempty"` // código a guardar en el historial
+	Reason     string `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// Campos por los que se pueden ordenar los listados de órdenes
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+)
+
+// OrderQuery filtros combinables, orden y página de un listado de órdenes.
+// Los filtros vacíos no se aplican.
+type OrderQuery struct {
+	Statuses     []string // cualquiera de estos estados
+	Workflow     string
+	UserID       string
+	CourierID    string
+	ReturnStatus string // con alguna devolución en este estado
+	Province     string // shipping.province
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	UpdatedFrom  *time.Time
+	UpdatedTo    *time.Time
+
+	SortBy string // SortCreatedAt o SortUpdatedAt
+	Desc   bool
+	Limit  int
+	After  *OrderCursor // seguir después de esta orden (página siguiente)
+}
+
+// StaleQuery órdenes de un estado que llevan demasiado tiempo sin cambios (reglas de SLA)
+type StaleQuery struct {
+	Workflow    string
+	Status      string
+	Before      time.Time // sin cambios desde antes de este momento
+	ByUpdate    bool      // compara updated_at (última novedad) en lugar de sla_since (inicio del SLA del estado)
+	ExcludeFlag string    // si no está vacío, omite las que ya tienen esa alerta para Status
+	Limit       int64
+}
+
+// OrderCursor posición en un listado: valor del campo de orden y order_id
+// (desempata órdenes con la misma fecha)
+type OrderCursor struct {
+	Value   time.Time
+	OrderID string
+}
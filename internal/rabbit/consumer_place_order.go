@@ -3,9 +3,11 @@ package rabbit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 
 	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
 	"order-status-service-2/internal/service"
 )
 
@@ -23,16 +25,16 @@ type PlacedOrderMessage struct {
 	Exchange      string `json:"exchange"`
 	RoutingKey    string `json:"routing_key"`
 	Message       struct {
-		OrderID  string `json:"orderId"`
-		CartID   string `json:"cartId"`
-		UserID   string `json:"userId"`
-		Articles []struct {
-			ArticleID string `json:"articleId"`
-			Quantity  int    `json:"quantity"`
-		} `json:"articles"`
+		OrderID  string           `json:"orderId"`
+		CartID   string           `json:"cartId"`
+		UserID   string           `json:"userId"`
+		Articles []dto.ArticleDTO `json:"articles"`
 		// Agregamos esto. Si el JSON trae "shipping", se guarda aquí.
 		// Si no lo trae, quedará vacío (Zero Value).
 		Shipping dto.ShippingDTO `json:"shipping"`
+		// Tipo de entrega (home_delivery, store_pickup, digital).
+		// Si no viene, el servicio usa home_delivery.
+		FulfillmentType string `json:"fulfillmentType"`
 	} `json:"message"`
 }
 
@@ -49,13 +51,14 @@ func (c *PlaceOrderConsumer) Handle(msg []byte) error {
 	// Si Rabbit envió datos, van llenos.
 	// Si Rabbit NO envió datos, va un struct vacío (AddressLine1 = "").
 	// Si viene vacío, el servicio usará la dirección por defecto.
-	_, err := c.Service.InitOrderStatus(
-		context.Background(),
-		event.Message.OrderID,
-		event.Message.UserID,
-		event.Message.Shipping,
-		true,
-	)
+	_, err := c.init(event, event.Message.FulfillmentType)
+
+	// La cola es autoack: si el tipo de entrega no existe, descartar el mensaje
+	// perdería la orden, así que se crea con el workflow por defecto.
+	if errors.Is(err, service.ErrUnknownWorkflow) {
+		log.Printf("⚠ Orden %s con tipo de entrega desconocido %q, se usa %s", event.Message.OrderID, event.Message.FulfillmentType, model.DefaultWorkflow)
+		_, err = c.init(event, "")
+	}
 
 	if err != nil {
 		log.Println("❌ Error creando estado inicial:", err)
@@ -65,3 +68,15 @@ func (c *PlaceOrderConsumer) Handle(msg []byte) error {
 	log.Println("✔ Estado inicial procesado para orden:", event.Message.OrderID)
 	return nil
 }
+
+func (c *PlaceOrderConsumer) init(event PlacedOrderMessage, workflow string) (*model.OrderStatus, error) {
+	return c.Service.InitOrderStatus(
+		context.Background(),
+		event.Message.OrderID,
+		event.Message.UserID,
+		workflow,
+		event.Message.Shipping,
+		event.Message.Articles,
+		true,
+	)
+}
@@ -0,0 +1,74 @@
+// publisher_status_changed.go
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"order-status-service-2/internal/service"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const statusChangedExchange = "order_status_changed"
+
+// StatusChangedPublisher es un TransitionHook que publica cada cambio de estado
+// en el exchange fanout "order_status_changed" para el resto de los microservicios.
+type StatusChangedPublisher struct {
+	ch *amqp091.Channel
+}
+
+func NewStatusChangedPublisher(ch *amqp091.Channel) (*StatusChangedPublisher, error) {
+	err := ch.ExchangeDeclare(
+		statusChangedExchange,
+		"fanout",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusChangedPublisher{ch: ch}, nil
+}
+
+type StatusChangedMessage struct {
+	OrderID   string    `json:"orderId"`
+	UserID    string    `json:"userId"`
+	Workflow  string    `json:"workflow"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason"`
+	ActorID   string    `json:"actorId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (p *StatusChangedPublisher) Name() string {
+	return "rabbit_status_changed"
+}
+
+func (p *StatusChangedPublisher) OnTransition(ctx context.Context, ev service.TransitionEvent) error {
+	body, err := json.Marshal(StatusChangedMessage{
+		OrderID:   ev.Order.OrderID,
+		UserID:    ev.Order.UserID,
+		Workflow:  ev.Order.Workflow,
+		From:      ev.From,
+		To:        ev.To,
+		Reason:    ev.Record.Reason,
+		ActorID:   ev.ActorID,
+		Timestamp: ev.Record.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.ch.PublishWithContext(ctx, statusChangedExchange, "", false, false, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Timestamp:    ev.Record.Timestamp,
+		Body:         body,
+	})
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"order-status-service-2/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Catálogo de estados administrable, guardado en la colección state_catalog
+type MongoStateCatalogRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoStateCatalogRepository(db *mongo.Database) *MongoStateCatalogRepository {
+	return &MongoStateCatalogRepository{col: db.Collection("state_catalog")}
+}
+
+func (m *MongoStateCatalogRepository) FindAll(ctx context.Context) ([]*model.CatalogState, error) {
+	cur, err := m.col.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*model.CatalogState
+	for cur.Next(ctx) {
+		var v model.CatalogState
+		if err := cur.Decode(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, &v)
+	}
+	return out, nil
+}
+
+// Save inserta o reemplaza el estado (la clave es workflow + nombre)
+func (m *MongoStateCatalogRepository) Save(ctx context.Context, st *model.CatalogState) error {
+	now := time.Now().UTC()
+	if st.CreatedAt.IsZero() {
+		st.CreatedAt = now
+	}
+	st.UpdatedAt = now
+
+	filter := bson.M{"workflow": st.Workflow, "name": st.Name}
+	update := bson.M{"$set": st}
+	opts := options.Update().SetUpsert(true)
+	_, err := m.col.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+func (m *MongoStateCatalogRepository) Delete(ctx context.Context, workflow, name string) error {
+	_, err := m.col.DeleteOne(ctx, bson.M{"workflow": workflow, "name": name})
+	return err
+}
+
+// AssignDefaultWorkflow asigna el workflow por defecto a los estados
+// guardados antes de que el catálogo distinguiera workflows.
+func (m *MongoStateCatalogRepository) AssignDefaultWorkflow(ctx context.Context) error {
+	filter := bson.M{"workflow": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"workflow": model.DefaultWorkflow}}
+	_, err := m.col.UpdateMany(ctx, filter, update)
+	return err
+}
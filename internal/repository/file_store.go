@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrFileNotFound = errors.New("archivo no encontrado")
+
+// Archivos de las constancias de entrega, en GridFS (bucket delivery_proofs)
+type MongoFileStore struct {
+	db *mongo.Database
+}
+
+func NewMongoFileStore(db *mongo.Database) *MongoFileStore {
+	return &MongoFileStore{db: db}
+}
+
+// bucket arma un bucket por operación: los deadlines de GridFS se configuran
+// sobre el bucket, así que no se comparte entre requests.
+func (m *MongoFileStore) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	b, err := gridfs.NewBucket(m.db, options.GridFSBucket().SetName("delivery_proofs"))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = b.SetWriteDeadline(deadline)
+		_ = b.SetReadDeadline(deadline)
+	}
+	return b, nil
+}
+
+func (m *MongoFileStore) Upload(ctx context.Context, filename, contentType string, content io.Reader) (string, error) {
+	b, err := m.bucket(ctx)
+	if err != nil {
+		return "", err
+	}
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	id, err := b.UploadFromStream(filename, content, opts)
+	if err != nil {
+		return "", err
+	}
+	return id.Hex(), nil
+}
+
+func (m *MongoFileStore) Open(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	id, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	b, err := m.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := b.OpenDownloadStream(id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (m *MongoFileStore) Delete(ctx context.Context, fileID string) error {
+	id, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return ErrFileNotFound
+	}
+	b, err := m.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	err = b.DeleteContext(ctx, id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return ErrFileNotFound
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Locks con vencimiento (colección scheduler_locks), para que una sola réplica
+// ejecute cada tarea periódica.
+type MongoLockRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoLockRepository(db *mongo.Database) *MongoLockRepository {
+	return &MongoLockRepository{col: db.Collection("scheduler_locks")}
+}
+
+// Acquire toma (o renueva) el lock si está libre, vencido o ya es de este owner.
+// Si otra réplica lo tiene, el upsert choca con el _id existente y devuelve false.
+func (m *MongoLockRepository) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$lte": now}},
+			bson.M{"owner": owner},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}}
+
+	_, err := m.col.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release libera el lock si sigue siendo de este owner
+func (m *MongoLockRepository) Release(ctx context.Context, name, owner string) error {
+	_, err := m.col.DeleteOne(ctx, bson.M{"_id": name, "owner": owner})
+	return err
+}
@@ -0,0 +1,563 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"order-status-service-2/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Implementación en memoria de service.OrderRepository (STORAGE=memory), para
+// desarrollo local y tests. Tiene la misma semántica que MongoOrderRepository:
+// mismos errores, mismo manejo de versiones y de registro actual del historial.
+// Las órdenes se guardan y se devuelven como copias, así que nadie de afuera
+// modifica lo guardado sin pasar por el repositorio.
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[string]*model.OrderStatus
+}
+
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{orders: map[string]*model.OrderStatus{}}
+}
+
+// clone copia el documento pasando por BSON, igual que un ida y vuelta a Mongo
+// (fechas en UTC con precisión de milisegundos, campos omitempty vacíos en nil).
+func clone[T any](v *T) (*T, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out T
+	if err := bson.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// cloneAll copia los documentos indicados
+func cloneAll[T any](docs []*T) ([]*T, error) {
+	out := make([]*T, 0, len(docs))
+	for _, o := range docs {
+		c, err := clone(o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Save inserta una orden nueva; si ya existe devuelve ErrDuplicateOrder.
+func (m *MemoryOrderRepository) Save(ctx context.Context, o *model.OrderStatus) error {
+	now := time.Now().UTC()
+
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = now
+		// Primer estado en historial
+		o.History = []model.StatusRecord{
+			{
+				Status:    o.Status,
+				Timestamp: now,
+				UserID:    o.UserID, // creador
+				Reason:    "Orden creada",
+				Current:   true,
+			},
+		}
+	}
+	o.UpdatedAt = now
+
+	stored, err := clone(o)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.orders[o.OrderID]; ok {
+		return ErrDuplicateOrder
+	}
+	m.orders[o.OrderID] = stored
+	return nil
+}
+
+func (m *MemoryOrderRepository) FindByOrderID(ctx context.Context, orderID string) (*model.OrderStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.orders[orderID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(o)
+}
+
+// UpdateStatus registra la transición sólo si la orden sigue en expectedVersion;
+// si cambió, devuelve ErrVersionConflict.
+func (m *MemoryOrderRepository) UpdateStatus(ctx context.Context, orderID string, expectedVersion int64, status string, slaSince time.Time, record model.StatusRecord) error {
+	return m.updateVersioned(orderID, expectedVersion, func(o *model.OrderStatus) error {
+		o.Status = status
+		o.SLASince = slaSince
+		o.History = appendCurrentRecord(o.History, record)
+		return nil
+	})
+}
+
+// AssignCourier asigna el repartidor y agrega el registro de la asignación como
+// registro actual, sin cambiar el estado ni el reloj del SLA.
+func (m *MemoryOrderRepository) AssignCourier(ctx context.Context, orderID string, expectedVersion int64, courierID string, record model.StatusRecord) error {
+	return m.updateVersioned(orderID, expectedVersion, func(o *model.OrderStatus) error {
+		o.CourierID = courierID
+		o.History = appendCurrentRecord(o.History, record)
+		return nil
+	})
+}
+
+func (m *MemoryOrderRepository) AddDeliveryAttempt(ctx context.Context, orderID string, expectedVersion int64, attempt model.DeliveryAttempt) error {
+	return m.updateVersioned(orderID, expectedVersion, func(o *model.OrderStatus) error {
+		o.Attempts = append(o.Attempts, attempt)
+		return nil
+	})
+}
+
+// ReplaceHistory reescribe el estado y el historial completo (reparación de
+// inconsistencias), sólo si la orden sigue en expectedVersion.
+func (m *MemoryOrderRepository) ReplaceHistory(ctx context.Context, orderID string, expectedVersion int64, status string, history []model.StatusRecord) error {
+	return m.updateVersioned(orderID, expectedVersion, func(o *model.OrderStatus) error {
+		o.Status = status
+		o.History = slices.Clone(history)
+		return nil
+	})
+}
+
+// appendCurrentRecord desmarca los registros actuales y agrega record al final
+func appendCurrentRecord(history []model.StatusRecord, record model.StatusRecord) []model.StatusRecord {
+	out := make([]model.StatusRecord, 0, len(history)+1)
+	for _, h := range history {
+		h.Current = false
+		out = append(out, h)
+	}
+	return append(out, record)
+}
+
+// updateVersioned aplica fn si la orden sigue en expectedVersion (ErrNotFound si
+// no existe, ErrVersionConflict si cambió), y aumenta la versión.
+func (m *MemoryOrderRepository) updateVersioned(orderID string, expectedVersion int64, fn func(o *model.OrderStatus) error) error {
+	return m.update(orderID, ErrNotFound, func(o *model.OrderStatus) error {
+		if o.Version != expectedVersion {
+			return ErrVersionConflict
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+		o.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// update aplica fn a una copia de la orden y, si no falla, la guarda con la
+// versión aumentada: una escritura que falla no deja cambios a medias.
+// Si la orden no existe devuelve notFound.
+func (m *MemoryOrderRepository) update(orderID string, notFound error, fn func(o *model.OrderStatus) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.orders[orderID]
+	if !ok {
+		return notFound
+	}
+	o, err := clone(cur)
+	if err != nil {
+		return err
+	}
+	if err := fn(o); err != nil {
+		return err
+	}
+	o.Version++
+	stored, err := clone(o)
+	if err != nil {
+		return err
+	}
+	m.orders[orderID] = stored
+	return nil
+}
+
+// FindPage devuelve hasta q.Limit órdenes que cumplen los filtros, ordenadas por
+// q.SortBy y order_id, empezando después de q.After.
+func (m *MemoryOrderRepository) FindPage(ctx context.Context, q model.OrderQuery) ([]*model.OrderStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*model.OrderStatus
+	for _, o := range m.orders {
+		if matchesQuery(o, q) {
+			out = append(out, o)
+		}
+	}
+
+	// compare ordena por el campo de orden y desempata por order_id
+	compare := func(a *model.OrderStatus, value time.Time, orderID string) int {
+		c := sortValue(a, q.SortBy).Compare(value)
+		if c == 0 {
+			c = strings.Compare(a.OrderID, orderID)
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+	if q.After != nil {
+		out = slices.DeleteFunc(out, func(o *model.OrderStatus) bool {
+			return compare(o, q.After.Value, q.After.OrderID) <= 0
+		})
+	}
+	slices.SortFunc(out, func(a, b *model.OrderStatus) int {
+		return compare(a, sortValue(b, q.SortBy), b.OrderID)
+	})
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return cloneAll(out)
+}
+
+func matchesQuery(o *model.OrderStatus, q model.OrderQuery) bool {
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, o.Status) {
+		return false
+	}
+	if q.Workflow != "" && !inWorkflow(o, q.Workflow) {
+		return false
+	}
+	if q.UserID != "" && o.UserID != q.UserID {
+		return false
+	}
+	if q.CourierID != "" && o.CourierID != q.CourierID {
+		return false
+	}
+	if q.ReturnStatus != "" && !slices.ContainsFunc(o.Returns, func(r model.OrderReturn) bool { return r.Status == q.ReturnStatus }) {
+		return false
+	}
+	if q.Province != "" && o.Shipping.Province != q.Province {
+		return false
+	}
+	return inRange(o.CreatedAt, q.CreatedFrom, q.CreatedTo) && inRange(o.UpdatedAt, q.UpdatedFrom, q.UpdatedTo)
+}
+
+// inRange indica si t está en [from, to); los extremos nil no se aplican
+func inRange(t time.Time, from, to *time.Time) bool {
+	return (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+}
+
+// inWorkflow: las órdenes sin workflow guardado pertenecen al workflow por defecto
+func inWorkflow(o *model.OrderStatus, workflow string) bool {
+	return o.Workflow == workflow || (o.Workflow == "" && workflow == model.DefaultWorkflow)
+}
+
+func sortValue(o *model.OrderStatus, sortBy string) time.Time {
+	if sortBy == model.SortUpdatedAt {
+		return o.UpdatedAt
+	}
+	return o.CreatedAt
+}
+
+// Pesos de los campos en la búsqueda por texto (los mismos que el índice orders_text)
+var searchWeights = []struct {
+	weight int
+	value  func(o *model.OrderStatus) string
+}{
+	{10, func(o *model.OrderStatus) string { return o.OrderID }},
+	{5, func(o *model.OrderStatus) string { return o.UserID }},
+	{3, func(o *model.OrderStatus) string { return o.Shipping.AddressLine1 }},
+	{3, func(o *model.OrderStatus) string { return o.Shipping.City }},
+	{1, func(o *model.OrderStatus) string { return o.Shipping.PostalCode }},
+	{1, func(o *model.OrderStatus) string { return o.Shipping.Province }},
+	{1, func(o *model.OrderStatus) string { return o.Shipping.Country }},
+	{1, func(o *model.OrderStatus) string { return o.Shipping.Comments }},
+}
+
+// Search busca igual que MongoOrderRepository.Search: primero por prefijo de
+// order_id y después por palabras, de más a menos relevante. La parte de texto
+// aproxima al índice de Mongo: ignora mayúsculas y tildes, pero no reduce las
+// palabras a su raíz (en Mongo "envíos" también encuentra "envío").
+func (m *MemoryOrderRepository) Search(ctx context.Context, text string, limit int) ([]*model.OrderStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var prefix []*model.OrderStatus
+	for _, o := range m.orders {
+		if strings.HasPrefix(o.OrderID, text) {
+			prefix = append(prefix, o)
+		}
+	}
+	sort.Slice(prefix, func(i, j int) bool { return prefix[i].OrderID < prefix[j].OrderID })
+
+	terms := searchTerms(text)
+	type scored struct {
+		order *model.OrderStatus
+		score int
+	}
+	var matches []scored
+	for _, o := range m.orders {
+		score := 0
+		for _, f := range searchWeights {
+			words := searchTerms(f.value(o))
+			for _, t := range terms {
+				if slices.Contains(words, t) {
+					score += f.weight
+				}
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{o, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].order.OrderID < matches[j].order.OrderID
+	})
+
+	out := prefix
+	for _, s := range matches {
+		if !slices.Contains(out, s.order) {
+			out = append(out, s.order)
+		}
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return cloneAll(out)
+}
+
+// searchTerms separa el texto en palabras en minúscula y sin tildes
+func searchTerms(text string) []string {
+	text = strings.Map(func(r rune) rune {
+		switch r {
+		case 'á', 'Á':
+			return 'a'
+		case 'é', 'É':
+			return 'e'
+		case 'í', 'Í':
+			return 'i'
+		case 'ó', 'Ó':
+			return 'o'
+		case 'ú', 'Ú', 'ü', 'Ü':
+			return 'u'
+		case 'ñ', 'Ñ':
+			return 'n'
+		}
+		return unicode.ToLower(r)
+	}, text)
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// CountByStatus cuenta las órdenes de un workflow que están en el estado indicado.
+func (m *MemoryOrderRepository) CountByStatus(ctx context.Context, workflow, status string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var n int64
+	for _, o := range m.orders {
+		if o.Status == status && inWorkflow(o, workflow) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CountHeldFrom cuenta las órdenes de un workflow que están en espera y, al
+// reanudarse, vuelven a status (HeldFrom del registro actual).
+func (m *MemoryOrderRepository) CountHeldFrom(ctx context.Context, workflow, status string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var n int64
+	for _, o := range m.orders {
+		if !inWorkflow(o, workflow) {
+			continue
+		}
+		if slices.ContainsFunc(o.History, func(h model.StatusRecord) bool { return h.Current && h.HeldFrom == status }) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// FindStale devuelve las órdenes en q.Status cuyo SLA corre desde antes de q.Before
+// (o, con q.ByUpdate, sin novedades desde entonces), de la más antigua a la más nueva.
+// Las órdenes sin sla_since usan updated_at.
+func (m *MemoryOrderRepository) FindStale(ctx context.Context, q model.StaleQuery) ([]*model.OrderStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*model.OrderStatus
+	for _, o := range m.orders {
+		if !inWorkflow(o, q.Workflow) || o.Status != q.Status {
+			continue
+		}
+		since := o.SLASince
+		if since.IsZero() || q.ByUpdate {
+			since = o.UpdatedAt
+		}
+		if !since.Before(q.Before) {
+			continue
+		}
+		if q.ExcludeFlag != "" && hasFlag(o, q.ExcludeFlag, q.Status) {
+			continue
+		}
+		out = append(out, o)
+	}
+	slices.SortFunc(out, func(a, b *model.OrderStatus) int {
+		if !q.ByUpdate {
+			if c := a.SLASince.Compare(b.SLASince); c != 0 {
+				return c
+			}
+		}
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	if q.Limit > 0 && int64(len(out)) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return cloneAll(out)
+}
+
+func hasFlag(o *model.OrderStatus, code, status string) bool {
+	return slices.ContainsFunc(o.Flags, func(f model.OrderFlag) bool {
+		return f.Code == code && f.Status == status
+	})
+}
+
+// AddFlag agrega la alerta sólo si la orden no la tiene ya para ese estado.
+// Si la orden no existe no hace nada.
+func (m *MemoryOrderRepository) AddFlag(ctx context.Context, orderID string, flag model.OrderFlag) error {
+	err := m.update(orderID, nil, func(o *model.OrderStatus) error {
+		if hasFlag(o, flag.Code, flag.Status) {
+			return errAlreadyFlagged
+		}
+		o.Flags = append(o.Flags, flag)
+		return nil
+	})
+	if errors.Is(err, errAlreadyFlagged) {
+		return nil
+	}
+	return err
+}
+
+// errAlreadyFlagged corta AddFlag sin escribir (no se devuelve afuera)
+var errAlreadyFlagged = errors.New("la orden ya tiene la alerta")
+
+// UpdateItemStatus cambia el estado de una línea y agrega el registro a su historial,
+// sólo si la línea sigue en from.
+func (m *MemoryOrderRepository) UpdateItemStatus(ctx context.Context, orderID, articleID, from, status string, record model.StatusRecord) error {
+	return m.update(orderID, ErrNotFound, func(o *model.OrderStatus) error {
+		i := slices.IndexFunc(o.Items, func(it model.LineItem) bool { return it.ArticleID == articleID })
+		if i < 0 {
+			return ErrNotFound
+		}
+		if o.Items[i].Status != from {
+			return ErrVersionConflict
+		}
+		o.Items[i].Status = status
+		o.Items[i].History = append(o.Items[i].History, record)
+		o.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// AddShipment agrega el paquete a la orden y pasa sus líneas a itemStatus,
+// sólo si la orden sigue en expectedVersion.
+func (m *MemoryOrderRepository) AddShipment(ctx context.Context, orderID string, expectedVersion int64, shipment model.Shipment, itemStatus string, itemRecord model.StatusRecord) error {
+	return m.updateVersioned(orderID, expectedVersion, func(o *model.OrderStatus) error {
+		for i := range o.Items {
+			if slices.Contains(shipment.Articles, o.Items[i].ArticleID) {
+				o.Items[i].Status = itemStatus
+				o.Items[i].History = append(o.Items[i].History, itemRecord)
+			}
+		}
+		o.Shipments = append(o.Shipments, shipment)
+		return nil
+	})
+}
+
+// UpdateShipmentStatus cambia el estado de un paquete y de sus líneas (articles),
+// agregando el registro al historial de cada uno, sólo si el paquete sigue en from.
+func (m *MemoryOrderRepository) UpdateShipmentStatus(ctx context.Context, orderID, shipmentID string, articles []string, from, status string, record model.StatusRecord) error {
+	return m.update(orderID, ErrNotFound, func(o *model.OrderStatus) error {
+		i := slices.IndexFunc(o.Shipments, func(sh model.Shipment) bool { return sh.ShipmentID == shipmentID })
+		if i < 0 {
+			return ErrNotFound
+		}
+		if o.Shipments[i].Status != from {
+			return ErrVersionConflict
+		}
+		o.Shipments[i].Status = status
+		o.Shipments[i].History = append(o.Shipments[i].History, record)
+		for j := range o.Items {
+			if slices.Contains(articles, o.Items[j].ArticleID) {
+				o.Items[j].Status = status
+				o.Items[j].History = append(o.Items[j].History, record)
+			}
+		}
+		o.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// AddReturn agrega una devolución a la orden. El historial de la orden no se toca.
+func (m *MemoryOrderRepository) AddReturn(ctx context.Context, orderID string, ret model.OrderReturn) error {
+	return m.update(orderID, ErrNotFound, func(o *model.OrderStatus) error {
+		o.Returns = append(o.Returns, ret)
+		o.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// UpdateReturnStatus cambia el estado de una devolución y agrega el registro a su
+// historial, sólo si la devolución sigue en from.
+func (m *MemoryOrderRepository) UpdateReturnStatus(ctx context.Context, orderID, returnID, from, status string, record model.StatusRecord) error {
+	return m.update(orderID, ErrNotFound, func(o *model.OrderStatus) error {
+		i := slices.IndexFunc(o.Returns, func(r model.OrderReturn) bool { return r.ReturnID == returnID })
+		if i < 0 {
+			return ErrNotFound
+		}
+		if o.Returns[i].Status != from {
+			return ErrVersionConflict
+		}
+		now := time.Now().UTC()
+		o.Returns[i].Status = status
+		o.Returns[i].UpdatedAt = now
+		o.Returns[i].History = append(o.Returns[i].History, record)
+		o.UpdatedAt = now
+		return nil
+	})
+}
+
+// Scan recorre todas las órdenes por order_id. Trabaja sobre una copia, así que
+// fn puede escribir en el repositorio (ej: ReplaceHistory al reparar).
+func (m *MemoryOrderRepository) Scan(ctx context.Context, fn func(*model.OrderStatus) error) error {
+	m.mu.RLock()
+	all := make([]*model.OrderStatus, 0, len(m.orders))
+	for _, o := range m.orders {
+		all = append(all, o)
+	}
+	snapshot, err := cloneAll(all)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].OrderID < snapshot[j].OrderID })
+	for _, o := range snapshot {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package repository_test
+
+import (
+	"testing"
+
+	"order-status-service-2/internal/repository/repotest"
+)
+
+func TestMemoryOrderRepository(t *testing.T) {
+	repotest.Run(t, repotest.Memory)
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"order-status-service-2/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Versiones en memoria del resto de los repositorios, para levantar el servicio
+// sin Mongo con STORAGE=memory. Se pierden al reiniciar y no se comparten entre
+// réplicas: sólo sirven para desarrollo local y tests.
+
+// Catálogo de estados en memoria (ver MongoStateCatalogRepository)
+type MemoryStateCatalogRepository struct {
+	mu     sync.RWMutex
+	states []*model.CatalogState
+}
+
+func NewMemoryStateCatalogRepository() *MemoryStateCatalogRepository {
+	return &MemoryStateCatalogRepository{}
+}
+
+func (m *MemoryStateCatalogRepository) FindAll(ctx context.Context) ([]*model.CatalogState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return cloneAll(m.states)
+}
+
+// Save inserta o reemplaza el estado (la clave es workflow + nombre)
+func (m *MemoryStateCatalogRepository) Save(ctx context.Context, st *model.CatalogState) error {
+	now := time.Now().UTC()
+	if st.CreatedAt.IsZero() {
+		st.CreatedAt = now
+	}
+	st.UpdatedAt = now
+
+	stored, err := clone(st)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.index(st.Workflow, st.Name); i >= 0 {
+		m.states[i] = stored
+	} else {
+		m.states = append(m.states, stored)
+	}
+	return nil
+}
+
+func (m *MemoryStateCatalogRepository) Delete(ctx context.Context, workflow, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.index(workflow, name); i >= 0 {
+		m.states = slices.Delete(m.states, i, i+1)
+	}
+	return nil
+}
+
+// AssignDefaultWorkflow asigna el workflow por defecto a los estados sin workflow
+func (m *MemoryStateCatalogRepository) AssignDefaultWorkflow(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, st := range m.states {
+		if st.Workflow == "" {
+			st.Workflow = model.DefaultWorkflow
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStateCatalogRepository) index(workflow, name string) int {
+	return slices.IndexFunc(m.states, func(st *model.CatalogState) bool {
+		return st.Workflow == workflow && st.Name == name
+	})
+}
+
+// Transiciones programadas en memoria (ver MongoScheduleRepository)
+type MemoryScheduleRepository struct {
+	mu        sync.RWMutex
+	schedules []*model.ScheduledTransition
+}
+
+func NewMemoryScheduleRepository() *MemoryScheduleRepository {
+	return &MemoryScheduleRepository{}
+}
+
+func (m *MemoryScheduleRepository) Save(ctx context.Context, st *model.ScheduledTransition) error {
+	stored, err := clone(st)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules = append(m.schedules, stored)
+	return nil
+}
+
+func (m *MemoryScheduleRepository) FindByID(ctx context.Context, orderID, scheduleID string) (*model.ScheduledTransition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, st := range m.schedules {
+		if st.OrderID == orderID && st.ScheduleID == scheduleID {
+			return clone(st)
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+// FindByOrderID devuelve las transiciones programadas de la orden, por fecha
+func (m *MemoryScheduleRepository) FindByOrderID(ctx context.Context, orderID string) ([]*model.ScheduledTransition, error) {
+	return m.find(0, func(st *model.ScheduledTransition) bool { return st.OrderID == orderID })
+}
+
+// FindDue devuelve las pendientes con fecha vencida y las que quedaron en processing
+// con la toma vencida, de la más vieja a la más nueva
+func (m *MemoryScheduleRepository) FindDue(ctx context.Context, now, staleBefore time.Time, limit int64) ([]*model.ScheduledTransition, error) {
+	return m.find(limit, func(st *model.ScheduledTransition) bool {
+		return (st.State == model.SchedulePending && !st.DueAt.After(now)) || claimExpired(st, staleBefore)
+	})
+}
+
+// Claim pasa la transición a processing si está pendiente o si su toma venció.
+// Devuelve false si otro proceso la tiene (o ya no está por aplicarse).
+func (m *MemoryScheduleRepository) Claim(ctx context.Context, scheduleID string, staleBefore time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, st := range m.schedules {
+		if st.ScheduleID == scheduleID && (st.State == model.SchedulePending || claimExpired(st, staleBefore)) {
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			st.State, st.ClaimedAt = model.ScheduleProcessing, &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// claimExpired indica si la transición quedó en processing desde antes de staleBefore
+func claimExpired(st *model.ScheduledTransition, staleBefore time.Time) bool {
+	return st.State == model.ScheduleProcessing && (st.ClaimedAt == nil || st.ClaimedAt.Before(staleBefore))
+}
+
+// FindPendingByStatus devuelve las que todavía no se aplicaron (pendientes o en proceso) hacia ese estado
+func (m *MemoryScheduleRepository) FindPendingByStatus(ctx context.Context, status string) ([]*model.ScheduledTransition, error) {
+	return m.find(0, func(st *model.ScheduledTransition) bool {
+		return st.Status == status && (st.State == model.SchedulePending || st.State == model.ScheduleProcessing)
+	})
+}
+
+// UpdateState pasa la transición de "from" a "to" sólo si sigue en "from".
+// Devuelve false si otro proceso la cambió antes.
+func (m *MemoryScheduleRepository) UpdateState(ctx context.Context, scheduleID, from, to, result string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, st := range m.schedules {
+		if st.ScheduleID == scheduleID && st.State == from {
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			st.State, st.Result, st.ProcessedAt = to, result, &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryScheduleRepository) find(limit int64, match func(st *model.ScheduledTransition) bool) ([]*model.ScheduledTransition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*model.ScheduledTransition
+	for _, st := range m.schedules {
+		if match(st) {
+			out = append(out, st)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b *model.ScheduledTransition) int { return a.DueAt.Compare(b.DueAt) })
+	if limit > 0 && int64(len(out)) > limit {
+		out = out[:limit]
+	}
+	return cloneAll(out)
+}
+
+// Locks en memoria (ver MongoLockRepository). Con una sola réplica siempre se obtienen,
+// salvo que otro owner del mismo proceso tenga el lock vigente.
+type MemoryLockRepository struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+func NewMemoryLockRepository() *MemoryLockRepository {
+	return &MemoryLockRepository{locks: map[string]memoryLock{}}
+}
+
+// Acquire toma (o renueva) el lock si está libre, vencido o ya es de este owner.
+func (m *MemoryLockRepository) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if l, ok := m.locks[name]; ok && l.owner != owner && l.expiresAt.After(now) {
+		return false, nil
+	}
+	m.locks[name] = memoryLock{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Release libera el lock si sigue siendo de este owner
+func (m *MemoryLockRepository) Release(ctx context.Context, name, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.locks[name]; ok && l.owner == owner {
+		delete(m.locks, name)
+	}
+	return nil
+}
+
+// Archivos de las constancias en memoria (ver MongoFileStore)
+type MemoryFileStore struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+func NewMemoryFileStore() *MemoryFileStore {
+	return &MemoryFileStore{files: map[string][]byte{}}
+}
+
+func (m *MemoryFileStore) Upload(ctx context.Context, filename, contentType string, content io.Reader) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	id := primitive.NewObjectID().Hex() // mismo formato de id que GridFS
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[id] = data
+	return id, nil
+}
+
+func (m *MemoryFileStore) Open(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[fileID]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MemoryFileStore) Delete(ctx context.Context, fileID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[fileID]; !ok {
+		return ErrFileNotFound
+	}
+	delete(m.files, fileID)
+	return nil
+}
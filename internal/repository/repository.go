@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
 	"order-status-service-2/internal/model"
@@ -12,7 +13,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var ErrNotFound = errors.New("orden no encontrada")
+var (
+	ErrNotFound        = errors.New("orden no encontrada")
+	ErrVersionConflict = errors.New("la orden fue modificada por otra operación")
+	ErrDuplicateOrder  = errors.New("la orden ya existe")
+)
 
 // Mongo implementation
 type MongoOrderRepository struct {
@@ -23,6 +28,8 @@ func NewMongoOrderRepository(db *mongo.Database) *MongoOrderRepository {
 	return &MongoOrderRepository{col: db.Collection("order_statuses")}
 }
 
+// Save inserta una orden nueva. Si ya existe una con el mismo order_id (índice
+// único, ver migrations) devuelve ErrDuplicateOrder.
 func (m *MongoOrderRepository) Save(ctx context.Context, o *model.OrderStatus) error {
 	now := time.Now().UTC()
 
@@ -41,10 +48,10 @@ func (m *MongoOrderRepository) Save(ctx context.Context, o *model.OrderStatus) e
 	}
 	o.UpdatedAt = now
 
-	filter := bson.M{"order_id": o.OrderID}
-	update := bson.M{"$set": o}
-	opts := options.Update().SetUpsert(true)
-	_, err := m.col.UpdateOne(ctx, filter, update, opts)
+	_, err := m.col.InsertOne(ctx, o)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateOrder
+	}
 	return err
 }
 
@@ -57,47 +64,152 @@ func (m *MongoOrderRepository) FindByOrderID(ctx context.Context, orderID string
 	return &res, err
 }
 
-func (m *MongoOrderRepository) UpdateStatus(ctx context.Context, orderID, status string, record model.StatusRecord) error {
+// UpdateStatus registra la transición sólo si la orden sigue en expectedVersion
+// (control de concurrencia optimista); si cambió, devuelve ErrVersionConflict.
+//
+// Todo se hace en un único update con pipeline (MongoDB 4.2+): desmarcar los
+// registros actuales, agregar el nuevo, cambiar el estado y aumentar la versión.
+// Al ser una sola escritura sobre un documento es atómica tanto en un servidor
+// standalone como en un replica set, así que no hace falta una transacción y
+// nunca queda una orden sin registro actual (o con dos).
+func (m *MongoOrderRepository) UpdateStatus(ctx context.Context, orderID string, expectedVersion int64, status string, slaSince time.Time, record model.StatusRecord) error {
+	filter := bson.M{
+		"order_id": orderID,
+		"version":  versionFilter(expectedVersion),
+	}
+
+	// $literal evita que textos que empiezan con "$" (ej: el motivo) se tomen como campos
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":     bson.M{"$literal": status},
+			"sla_since":  slaSince,
+			"updated_at": time.Now().UTC(),
+			"version":    nextVersion,
+			"history":    appendCurrent(record),
+		}}},
+	}
+	return m.updateVersioned(ctx, orderID, filter, update)
+}
 
-	// PASO 1: desmarcar el actual
+// AssignCourier asigna la orden a un repartidor y agrega al historial el registro de
+// la asignación (como registro actual, sin cambiar el estado ni el reloj del SLA).
+// Igual que UpdateStatus, es una única escritura condicionada a expectedVersion.
+func (m *MongoOrderRepository) AssignCourier(ctx context.Context, orderID string, expectedVersion int64, courierID string, record model.StatusRecord) error {
 	filter := bson.M{
-		"order_id":        orderID,
-		"history.current": true,
+		"order_id": orderID,
+		"version":  versionFilter(expectedVersion),
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"courier_id": bson.M{"$literal": courierID},
+			"updated_at": time.Now().UTC(),
+			"version":    nextVersion,
+			"history":    appendCurrent(record),
+		}}},
 	}
+	return m.updateVersioned(ctx, orderID, filter, update)
+}
 
-	update1 := bson.M{
-		"$set": bson.M{
-			"history.$.current": false,
-		},
+// AddDeliveryAttempt registra un intento de entrega fallido, sólo si la orden
+// sigue en expectedVersion (así dos intentos simultáneos no repiten el número).
+func (m *MongoOrderRepository) AddDeliveryAttempt(ctx context.Context, orderID string, expectedVersion int64, attempt model.DeliveryAttempt) error {
+	filter := bson.M{
+		"order_id": orderID,
+		"version":  versionFilter(expectedVersion),
+	}
+	update := bson.M{
+		"$push": bson.M{"delivery_attempts": attempt},
+		"$set":  bson.M{"updated_at": time.Now().UTC()},
+		"$inc":  bson.M{"version": 1},
 	}
+	return m.updateVersioned(ctx, orderID, filter, update)
+}
+
+// nextVersion aumenta la versión dentro de un update con pipeline
+var nextVersion = bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}}
 
-	r1, err := m.col.UpdateOne(ctx, filter, update1)
+// appendCurrent desmarca los registros actuales del historial y agrega record al final
+func appendCurrent(record model.StatusRecord) bson.M {
+	return bson.M{"$concatArrays": bson.A{
+		bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$history", bson.A{}}},
+			"as":    "h",
+			"in":    bson.M{"$mergeObjects": bson.A{"$$h", bson.M{"current": false}}},
+		}},
+		bson.A{bson.M{"$literal": record}},
+	}}
+}
+
+// updateVersioned aplica un update filtrado por versión y distingue una orden
+// inexistente (ErrNotFound) de una modificada en el medio (ErrVersionConflict).
+func (m *MongoOrderRepository) updateVersioned(ctx context.Context, orderID string, filter bson.M, update interface{}) error {
+	res, err := m.col.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
-	if r1.MatchedCount == 0 {
-		return ErrNotFound
+	if res.MatchedCount == 0 {
+		if _, err := m.FindByOrderID(ctx, orderID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
 	}
+	return nil
+}
 
-	// PASO 2: actualizar estado + pushear nuevo registro
-	filter2 := bson.M{"order_id": orderID}
+// versionFilter matchea la versión esperada; las órdenes creadas antes de
+// que existiera el campo cuentan como versión 0.
+func versionFilter(v int64) interface{} {
+	if v == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return v
+}
 
-	update2 := bson.M{
-		"$set": bson.M{
-			"status":     status,
-			"updated_at": time.Now().UTC(),
-		},
-		"$push": bson.M{
-			"history": record,
-		},
+// FindPage devuelve hasta q.Limit órdenes que cumplen los filtros, ordenadas por
+// q.SortBy y order_id, empezando después de q.After. Cada combinación de filtro
+// y orden tiene su índice (ver migrations).
+func (m *MongoOrderRepository) FindPage(ctx context.Context, q model.OrderQuery) ([]*model.OrderStatus, error) {
+	filter := bson.M{}
+	if len(q.Statuses) > 0 {
+		filter["status"] = bson.M{"$in": q.Statuses}
+	}
+	if q.Workflow != "" {
+		filter["workflow"] = workflowFilter(q.Workflow)
+	}
+	if q.UserID != "" {
+		filter["user_id"] = q.UserID
+	}
+	if q.CourierID != "" {
+		filter["courier_id"] = q.CourierID
+	}
+	if q.ReturnStatus != "" {
+		filter["returns.status"] = q.ReturnStatus
+	}
+	if q.Province != "" {
+		filter["shipping.province"] = q.Province
+	}
+	if r := dateRange(q.CreatedFrom, q.CreatedTo); r != nil {
+		filter["created_at"] = r
+	}
+	if r := dateRange(q.UpdatedFrom, q.UpdatedTo); r != nil {
+		filter["updated_at"] = r
 	}
 
-	_, err = m.col.UpdateOne(ctx, filter2, update2)
-	return err
-}
+	dir, cmp := 1, "$gt"
+	if q.Desc {
+		dir, cmp = -1, "$lt"
+	}
+	if q.After != nil {
+		filter["$and"] = bson.A{bson.M{"$or": bson.A{
+			bson.M{q.SortBy: bson.M{cmp: q.After.Value}},
+			bson.M{q.SortBy: q.After.Value, "order_id": bson.M{cmp: q.After.OrderID}},
+		}}}
+	}
 
-func (m *MongoOrderRepository) FindAll(ctx context.Context) ([]*model.OrderStatus, error) {
-	cur, err := m.col.Find(ctx, bson.M{})
+	opts := options.Find().
+		SetSort(bson.D{{Key: q.SortBy, Value: dir}, {Key: "order_id", Value: dir}}).
+		SetLimit(int64(q.Limit))
+	cur, err := m.col.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -111,11 +223,125 @@ func (m *MongoOrderRepository) FindAll(ctx context.Context) ([]*model.OrderStatu
 		}
 		out = append(out, &v)
 	}
+	return out, cur.Err()
+}
+
+// dateRange arma {$gte, $lt} con los extremos indicados (nil si no hay ninguno)
+func dateRange(from, to *time.Time) bson.M {
+	r := bson.M{}
+	if from != nil {
+		r["$gte"] = *from
+	}
+	if to != nil {
+		r["$lt"] = *to
+	}
+	if len(r) == 0 {
+		return nil
+	}
+	return r
+}
+
+// Search busca órdenes por texto y devuelve hasta limit, de la más a la menos relevante:
+// primero las que tienen un order_id que empieza con el texto (el índice de texto
+// no matchea prefijos), y después las del índice de texto sobre order_id, user_id y
+// los datos de envío, por score.
+func (m *MongoOrderRepository) Search(ctx context.Context, text string, limit int) ([]*model.OrderStatus, error) {
+	var out []*model.OrderStatus
+	seen := map[string]bool{}
+	add := func(cur *mongo.Cursor) error {
+		defer cur.Close(ctx)
+		for cur.Next(ctx) && len(out) < limit {
+			var v model.OrderStatus
+			if err := cur.Decode(&v); err != nil {
+				return err
+			}
+			if !seen[v.OrderID] {
+				seen[v.OrderID] = true
+				out = append(out, &v)
+			}
+		}
+		return cur.Err()
+	}
+
+	prefix := bson.M{"order_id": bson.M{"$regex": "^" + regexp.QuoteMeta(text)}}
+	cur, err := m.col.Find(ctx, prefix, options.Find().
+		SetSort(bson.D{{Key: "order_id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	if err := add(cur); err != nil {
+		return nil, err
+	}
+	if len(out) >= limit {
+		return out, nil
+	}
+
+	// Las que ya vinieron por prefijo se descartan: se piden esas de más para
+	// completar limit aunque todas vuelvan a aparecer por texto
+	score := bson.M{"$meta": "textScore"}
+	cur, err = m.col.Find(ctx, bson.M{"$text": bson.M{"$search": text}}, options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "order_id", Value: 1}}).
+		SetLimit(int64(limit+len(out))))
+	if err != nil {
+		return nil, err
+	}
+	if err := add(cur); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
-func (m *MongoOrderRepository) FindByStatus(ctx context.Context, status string) ([]*model.OrderStatus, error) {
-	cur, err := m.col.Find(ctx, bson.M{"status": status})
+// CountByStatus cuenta las órdenes de un workflow que están en el estado indicado.
+// Las órdenes sin workflow guardado pertenecen al workflow por defecto.
+func (m *MongoOrderRepository) CountByStatus(ctx context.Context, workflow, status string) (int64, error) {
+	filter := bson.M{"status": status, "workflow": workflowFilter(workflow)}
+	return m.col.CountDocuments(ctx, filter)
+}
+
+// CountHeldFrom cuenta las órdenes de un workflow que están en espera y, al
+// reanudarse, vuelven a status (held_from del registro actual).
+func (m *MongoOrderRepository) CountHeldFrom(ctx context.Context, workflow, status string) (int64, error) {
+	filter := bson.M{
+		"workflow": workflowFilter(workflow),
+		"history":  bson.M{"$elemMatch": bson.M{"current": true, "held_from": status}},
+	}
+	return m.col.CountDocuments(ctx, filter)
+}
+
+// workflowFilter filtra por workflow; las órdenes sin workflow guardado pertenecen al workflow por defecto.
+func workflowFilter(workflow string) interface{} {
+	if workflow == model.DefaultWorkflow {
+		return bson.M{"$in": bson.A{workflow, nil}}
+	}
+	return workflow
+}
+
+// FindStale devuelve las órdenes en q.Status cuyo SLA corre desde antes de q.Before
+// (o, con q.ByUpdate, sin novedades desde entonces), de la más antigua a la más nueva.
+// Las órdenes sin sla_since usan updated_at.
+func (m *MongoOrderRepository) FindStale(ctx context.Context, q model.StaleQuery) ([]*model.OrderStatus, error) {
+	filter := bson.M{
+		"workflow": workflowFilter(q.Workflow),
+		"status":   q.Status,
+	}
+	sort := bson.D{{Key: "updated_at", Value: 1}}
+	if q.ByUpdate {
+		filter["updated_at"] = bson.M{"$lt": q.Before}
+	} else {
+		filter["$or"] = bson.A{
+			bson.M{"sla_since": bson.M{"$lt": q.Before}},
+			bson.M{"sla_since": bson.M{"$exists": false}, "updated_at": bson.M{"$lt": q.Before}},
+		}
+		sort = append(bson.D{{Key: "sla_since", Value: 1}}, sort...)
+	}
+	if q.ExcludeFlag != "" {
+		filter["flags"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{"code": q.ExcludeFlag, "status": q.Status}}}
+	}
+
+	opts := options.Find().SetSort(sort).SetLimit(q.Limit)
+	cur, err := m.col.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -132,20 +358,197 @@ func (m *MongoOrderRepository) FindByStatus(ctx context.Context, status string)
 	return out, nil
 }
 
-func (m *MongoOrderRepository) FindByUserID(ctx context.Context, userID string) ([]*model.OrderStatus, error) {
-	cur, err := m.col.Find(ctx, bson.M{"user_id": userID})
+// AddFlag agrega la alerta sólo si la orden no la tiene ya para ese estado,
+// así dos réplicas no la duplican.
+func (m *MongoOrderRepository) AddFlag(ctx context.Context, orderID string, flag model.OrderFlag) error {
+	filter := bson.M{
+		"order_id": orderID,
+		"flags":    bson.M{"$not": bson.M{"$elemMatch": bson.M{"code": flag.Code, "status": flag.Status}}},
+	}
+	update := bson.M{"$push": bson.M{"flags": flag}, "$inc": bson.M{"version": 1}}
+	_, err := m.col.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// UpdateItemStatus cambia el estado de una línea y agrega el registro a su historial,
+// sólo si la línea sigue en from.
+func (m *MongoOrderRepository) UpdateItemStatus(ctx context.Context, orderID, articleID, from, status string, record model.StatusRecord) error {
+	update := bson.M{
+		"$set": bson.M{
+			"items.$[it].status": status,
+			"updated_at":         time.Now().UTC(),
+		},
+		"$inc": bson.M{"version": 1},
+		"$push": bson.M{
+			"items.$[it].history": record,
+		},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"it.article_id": articleID}},
+	})
+	return m.updateElem(ctx, orderID, "items", bson.M{"article_id": articleID}, from, update, opts)
+}
+
+// updateElem aplica update si el elemento de field que matchea elem sigue en el estado
+// from. Si no matchea, distingue entre orden o elemento inexistente (ErrNotFound) y un
+// estado que otra operación cambió en el medio (ErrVersionConflict).
+func (m *MongoOrderRepository) updateElem(ctx context.Context, orderID, field string, elem bson.M, from string, update interface{}, opts *options.UpdateOptions) error {
+	withStatus := bson.M{"status": from}
+	for k, v := range elem {
+		withStatus[k] = v
+	}
+	res, err := m.col.UpdateOne(ctx, bson.M{"order_id": orderID, field: bson.M{"$elemMatch": withStatus}}, update, opts)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	n, err := m.col.CountDocuments(ctx, bson.M{"order_id": orderID, field: bson.M{"$elemMatch": elem}})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// AddShipment agrega el paquete a la orden y pasa sus líneas a "Enviado" en una sola
+// escritura, sólo si la orden sigue en expectedVersion (la versión con la que se
+// validaron el estado y los artículos empaquetados).
+func (m *MongoOrderRepository) AddShipment(ctx context.Context, orderID string, expectedVersion int64, shipment model.Shipment, itemStatus string, itemRecord model.StatusRecord) error {
+	filter := bson.M{
+		"order_id": orderID,
+		"version":  versionFilter(expectedVersion),
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"items.$[it].status": itemStatus,
+			"updated_at":         time.Now().UTC(),
+		},
+		"$inc": bson.M{"version": 1},
+		"$push": bson.M{
+			"shipments":           shipment,
+			"items.$[it].history": itemRecord,
+		},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"it.article_id": bson.M{"$in": shipment.Articles}}},
+	})
+
+	res, err := m.col.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := m.FindByOrderID(ctx, orderID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// UpdateShipmentStatus cambia el estado de un paquete y de sus líneas (articles),
+// agregando el registro al historial de cada uno, sólo si el paquete sigue en from.
+func (m *MongoOrderRepository) UpdateShipmentStatus(ctx context.Context, orderID, shipmentID string, articles []string, from, status string, record model.StatusRecord) error {
+	update := bson.M{
+		"$set": bson.M{
+			"shipments.$[sh].status": status,
+			"items.$[it].status":     status,
+			"updated_at":             time.Now().UTC(),
+		},
+		"$inc": bson.M{"version": 1},
+		"$push": bson.M{
+			"shipments.$[sh].history": record,
+			"items.$[it].history":     record,
+		},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{
+			bson.M{"sh.shipment_id": shipmentID},
+			bson.M{"it.article_id": bson.M{"$in": articles}},
+		},
+	})
+	return m.updateElem(ctx, orderID, "shipments", bson.M{"shipment_id": shipmentID}, from, update, opts)
+}
+
+// AddReturn agrega una devolución a la orden. El historial de la orden no se toca.
+func (m *MongoOrderRepository) AddReturn(ctx context.Context, orderID string, ret model.OrderReturn) error {
+	res, err := m.col.UpdateOne(ctx,
+		bson.M{"order_id": orderID},
+		bson.M{
+			"$push": bson.M{"returns": ret},
+			"$inc":  bson.M{"version": 1},
+			"$set":  bson.M{"updated_at": time.Now().UTC()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateReturnStatus cambia el estado de una devolución y agrega el registro a su
+// historial, sólo si la devolución sigue en from.
+func (m *MongoOrderRepository) UpdateReturnStatus(ctx context.Context, orderID, returnID, from, status string, record model.StatusRecord) error {
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{
+			"returns.$[rt].status":     status,
+			"returns.$[rt].updated_at": now,
+			"updated_at":               now,
+		},
+		"$inc": bson.M{"version": 1},
+		"$push": bson.M{
+			"returns.$[rt].history": record,
+		},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"rt.return_id": returnID}},
+	})
+	return m.updateElem(ctx, orderID, "returns", bson.M{"return_id": returnID}, from, update, opts)
+}
+
+// Scan recorre todas las órdenes con un cursor, sin cargarlas todas en memoria.
+func (m *MongoOrderRepository) Scan(ctx context.Context, fn func(*model.OrderStatus) error) error {
+	cur, err := m.col.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "order_id", Value: 1}}))
+	if err != nil {
+		return err
 	}
 	defer cur.Close(ctx)
 
-	var out []*model.OrderStatus
 	for cur.Next(ctx) {
 		var v model.OrderStatus
 		if err := cur.Decode(&v); err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(&v); err != nil {
+			return err
 		}
-		out = append(out, &v)
 	}
-	return out, nil
+	return cur.Err()
+}
+
+// ReplaceHistory reescribe el estado y el historial completo de la orden (reparación
+// de inconsistencias), sólo si sigue en expectedVersion.
+func (m *MongoOrderRepository) ReplaceHistory(ctx context.Context, orderID string, expectedVersion int64, status string, history []model.StatusRecord) error {
+	filter := bson.M{
+		"order_id": orderID,
+		"version":  versionFilter(expectedVersion),
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":     status,
+			"history":    history,
+			"updated_at": time.Now().UTC(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	return m.updateVersioned(ctx, orderID, filter, update)
 }
@@ -0,0 +1,12 @@
+package repository_test
+
+import (
+	"testing"
+
+	"order-status-service-2/internal/repository/repotest"
+)
+
+// Corre contra MONGO_TEST_URI; sin esa variable la prueba se omite.
+func TestMongoOrderRepository(t *testing.T) {
+	repotest.Run(t, repotest.Mongo)
+}
@@ -0,0 +1,706 @@
+// Package repotest tiene las pruebas que tiene que pasar toda implementación de
+// service.OrderRepository. Se usa desde los tests de cada implementación:
+//
+//	func TestMongoOrderRepository(t *testing.T) {
+//		repotest.Run(t, repotest.Mongo)
+//	}
+//
+//	func TestMemoryOrderRepository(t *testing.T) {
+//		repotest.Run(t, repotest.Memory)
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/migrations"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository es lo que se prueba: service.OrderRepository y lo que usan el
+// scheduler de SLA y el chequeo de consistencia.
+type Repository interface {
+	service.OrderRepository
+	service.StaleOrderRepository
+	service.ConsistencyRepository
+}
+
+// Factory crea un repositorio vacío para una prueba
+type Factory func(t *testing.T) Repository
+
+// Run ejecuta todas las pruebas contra los repositorios que crea newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("FindByOrderID devuelve ErrNotFound", func(t *testing.T) {
+		testNotFound(t, newRepo(t))
+	})
+	t.Run("UpdateStatus deja un único registro actual", func(t *testing.T) {
+		testSingleCurrent(t, newRepo(t))
+	})
+	t.Run("UpdateStatus rechaza una versión vieja", func(t *testing.T) {
+		testStaleVersion(t, newRepo(t))
+	})
+	t.Run("UpdateStatus con escritores concurrentes", func(t *testing.T) {
+		testConcurrentWriters(t, newRepo(t))
+	})
+	t.Run("Save rechaza una orden repetida", func(t *testing.T) {
+		testDuplicateSave(t, newRepo(t))
+	})
+	t.Run("las escrituras sobre una orden inexistente devuelven ErrNotFound", func(t *testing.T) {
+		testWritesNotFound(t, newRepo(t))
+	})
+	t.Run("AssignCourier agrega un registro actual sin cambiar el estado", func(t *testing.T) {
+		testAssignCourier(t, newRepo(t))
+	})
+	t.Run("FindPage filtra, ordena y pagina", func(t *testing.T) {
+		testFindPage(t, newRepo(t))
+	})
+	t.Run("envíos, artículos y devoluciones", func(t *testing.T) {
+		testShipmentsAndReturns(t, newRepo(t))
+	})
+	t.Run("FindStale y AddFlag", func(t *testing.T) {
+		testStaleAndFlags(t, newRepo(t))
+	})
+	t.Run("CountByStatus y CountHeldFrom", func(t *testing.T) {
+		testCountHeld(t, newRepo(t))
+	})
+	t.Run("Scan y ReplaceHistory", func(t *testing.T) {
+		testScanAndReplace(t, newRepo(t))
+	})
+	t.Run("Search por prefijo y por texto", func(t *testing.T) {
+		testSearch(t, newRepo(t))
+	})
+}
+
+// Memory es la Factory de MemoryOrderRepository
+func Memory(t *testing.T) Repository {
+	return repository.NewMemoryOrderRepository()
+}
+
+// Mongo es la Factory de MongoOrderRepository. Usa MONGO_TEST_URI (la prueba se
+// omite si no está definida) y una base nueva por prueba, que se borra al final.
+// Aplica las migraciones, así la base tiene los mismos índices que en producción.
+func Mongo(t *testing.T) Repository {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI no definida")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("conectando a Mongo: %v", err)
+	}
+	db := client.Database(fmt.Sprintf("order_status_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	if _, err := migrations.Run(ctx, db, repository.NewMongoLockRepository(db), "repotest"); err != nil {
+		t.Fatalf("migraciones: %v", err)
+	}
+	return repository.NewMongoOrderRepository(db)
+}
+
+// newOrder guarda una orden en "Pendiente" (versión 1) y la devuelve
+func newOrder(t *testing.T, repo Repository, orderID string) *model.OrderStatus {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return saveOrder(t, repo, &model.OrderStatus{
+		OrderID:  orderID,
+		UserID:   "user-1",
+		Workflow: model.DefaultWorkflow,
+		Status:   "Pendiente",
+		Version:  1,
+		SLASince: now,
+		History: []model.StatusRecord{
+			{Status: "Pendiente", Reason: "Orden inicializada", UserID: "user-1", Timestamp: now, Current: true},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+func saveOrder(t *testing.T, repo Repository, o *model.OrderStatus) *model.OrderStatus {
+	t.Helper()
+	if err := repo.Save(context.Background(), o); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return o
+}
+
+func record(status string) model.StatusRecord {
+	return model.StatusRecord{
+		Status:    status,
+		Reason:    "$motivo con signo pesos",
+		UserID:    "admin-1",
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+		Current:   true,
+	}
+}
+
+// checkInvariants verifica que haya exactamente un registro actual y que coincida con status
+func checkInvariants(t *testing.T, o *model.OrderStatus) {
+	t.Helper()
+	var current []model.StatusRecord
+	for _, h := range o.History {
+		if h.Current {
+			current = append(current, h)
+		}
+	}
+	if len(current) != 1 {
+		t.Fatalf("orden %s: %d registros actuales, se esperaba 1", o.OrderID, len(current))
+	}
+	if current[0].Status != o.Status {
+		t.Fatalf("orden %s: status %q pero el registro actual es %q", o.OrderID, o.Status, current[0].Status)
+	}
+}
+
+func testNotFound(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	if _, err := repo.FindByOrderID(ctx, "no-existe"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FindByOrderID: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+	if err := repo.UpdateStatus(ctx, "no-existe", 1, "Rechazado", time.Now(), record("Rechazado")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateStatus: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+}
+
+func testSingleCurrent(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(t, repo, "ORD-SINGLE")
+
+	for i, status := range []string{"En Preparación", "Enviado", "Entregado"} {
+		if err := repo.UpdateStatus(ctx, o.OrderID, int64(i+1), status, time.Now(), record(status)); err != nil {
+			t.Fatalf("UpdateStatus %q: %v", status, err)
+		}
+	}
+
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if got.Status != "Entregado" || got.Version != 4 || len(got.History) != 4 {
+		t.Fatalf("se esperaba Entregado, versión 4 y 4 registros; se obtuvo %q, %d y %d", got.Status, got.Version, len(got.History))
+	}
+	if got.History[3].Reason != "$motivo con signo pesos" {
+		t.Fatalf("motivo alterado: %q", got.History[3].Reason)
+	}
+}
+
+func testStaleVersion(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(t, repo, "ORD-STALE")
+
+	if err := repo.UpdateStatus(ctx, o.OrderID, 1, "En Preparación", time.Now(), record("En Preparación")); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.UpdateStatus(ctx, o.OrderID, 1, "Rechazado", time.Now(), record("Rechazado"))
+	if !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if got.Status != "En Preparación" || len(got.History) != 2 {
+		t.Fatalf("la escritura rechazada modificó la orden: %q con %d registros", got.Status, len(got.History))
+	}
+}
+
+// testConcurrentWriters lanza varios escritores que leen, y escriben con la versión
+// leída, reintentando ante conflicto. Al final tiene que haber un único registro
+// actual y un registro por escritura exitosa.
+func testConcurrentWriters(t *testing.T, repo Repository) {
+	const writers, attempts = 20, 50
+	ctx := context.Background()
+	o := newOrder(t, repo, "ORD-CONCURRENT")
+
+	var mu sync.Mutex
+	succeeded := 0
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			status := fmt.Sprintf("Estado %d", w)
+			for i := 0; i < attempts; i++ {
+				cur, err := repo.FindByOrderID(ctx, o.OrderID)
+				if err != nil {
+					t.Errorf("FindByOrderID: %v", err)
+					return
+				}
+				err = repo.UpdateStatus(ctx, o.OrderID, cur.Version, status, time.Now(), record(status))
+				if errors.Is(err, repository.ErrVersionConflict) {
+					continue
+				}
+				if err != nil {
+					t.Errorf("UpdateStatus: %v", err)
+					return
+				}
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if succeeded == 0 {
+		t.Fatal("ningún escritor pudo actualizar la orden")
+	}
+	if len(got.History) != succeeded+1 || got.Version != int64(succeeded+1) {
+		t.Fatalf("%d escrituras exitosas pero %d registros y versión %d", succeeded, len(got.History), got.Version)
+	}
+}
+
+func testDuplicateSave(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(t, repo, "ORD-DUP")
+
+	dup := *o
+	dup.Status = "Rechazado"
+	if err := repo.Save(ctx, &dup); !errors.Is(err, repository.ErrDuplicateOrder) {
+		t.Fatalf("se esperaba ErrDuplicateOrder, se obtuvo %v", err)
+	}
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "Pendiente" || got.Version != 1 {
+		t.Fatalf("el Save repetido modificó la orden: %q, versión %d", got.Status, got.Version)
+	}
+}
+
+func testWritesNotFound(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	const id = "no-existe"
+	writes := map[string]error{
+		"UpdateItemStatus":     repo.UpdateItemStatus(ctx, id, "A1", "Pendiente", "Empaquetado", record("Empaquetado")),
+		"UpdateShipmentStatus": repo.UpdateShipmentStatus(ctx, id, "S1", []string{"A1"}, "Enviado", "Entregado", record("Entregado")),
+		"AddReturn":            repo.AddReturn(ctx, id, model.OrderReturn{ReturnID: "R1"}),
+		"UpdateReturnStatus":   repo.UpdateReturnStatus(ctx, id, "R1", "Solicitada", "Aprobada", record("Aprobada")),
+		"AssignCourier":        repo.AssignCourier(ctx, id, 1, "courier-1", record("Enviado")),
+		"AddDeliveryAttempt":   repo.AddDeliveryAttempt(ctx, id, 1, model.DeliveryAttempt{Number: 1}),
+		"ReplaceHistory":       repo.ReplaceHistory(ctx, id, 1, "Pendiente", []model.StatusRecord{record("Pendiente")}),
+	}
+	for name, err := range writes {
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("%s: se esperaba ErrNotFound, se obtuvo %v", name, err)
+		}
+	}
+	// AddFlag sobre una orden inexistente no hace nada
+	if err := repo.AddFlag(ctx, id, model.OrderFlag{Code: "SLA_EXPIRED", Status: "Pendiente"}); err != nil {
+		t.Errorf("AddFlag: %v", err)
+	}
+}
+
+func testAssignCourier(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	o := newOrder(t, repo, "ORD-COURIER")
+
+	rec := record("Pendiente")
+	rec.CourierID = "courier-1"
+	if err := repo.AssignCourier(ctx, o.OrderID, 1, "courier-1", rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AssignCourier(ctx, o.OrderID, 1, "courier-2", rec); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+	if err := repo.AddDeliveryAttempt(ctx, o.OrderID, 2, model.DeliveryAttempt{Number: 1, Reason: "Nadie en el domicilio"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if got.Status != "Pendiente" || got.CourierID != "courier-1" || got.Version != 3 {
+		t.Fatalf("se esperaba Pendiente, courier-1 y versión 3; se obtuvo %q, %q y %d", got.Status, got.CourierID, got.Version)
+	}
+	if len(got.History) != 2 || got.History[1].CourierID != "courier-1" {
+		t.Fatalf("falta el registro de la asignación: %+v", got.History)
+	}
+	if len(got.Attempts) != 1 || got.Attempts[0].Reason != "Nadie en el domicilio" {
+		t.Fatalf("intentos de entrega: %+v", got.Attempts)
+	}
+}
+
+func testFindPage(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Millisecond).Add(-time.Hour)
+	// C y D tienen la misma fecha: desempata order_id
+	for _, o := range []struct {
+		id, user, status string
+		minutes          int
+	}{
+		{"ORD-PAGE-A", "user-1", "Pendiente", 0},
+		{"ORD-PAGE-B", "user-2", "Pendiente", 1},
+		{"ORD-PAGE-C", "user-1", "Enviado", 2},
+		{"ORD-PAGE-D", "user-2", "Pendiente", 2},
+		{"ORD-PAGE-E", "user-1", "Enviado", 3},
+	} {
+		saveOrder(t, repo, &model.OrderStatus{
+			OrderID:   o.id,
+			UserID:    o.user,
+			Workflow:  model.DefaultWorkflow,
+			Status:    o.status,
+			Version:   1,
+			History:   []model.StatusRecord{record(o.status)},
+			CreatedAt: base.Add(time.Duration(o.minutes) * time.Minute),
+		})
+	}
+	page := func(q model.OrderQuery) []string {
+		t.Helper()
+		if q.SortBy == "" {
+			q.SortBy = model.SortCreatedAt
+		}
+		orders, err := repo.FindPage(ctx, q)
+		if err != nil {
+			t.Fatalf("FindPage: %v", err)
+		}
+		var ids []string
+		for _, o := range orders {
+			ids = append(ids, o.OrderID)
+		}
+		return ids
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: se esperaba %v, se obtuvo %v", name, want, got)
+		}
+	}
+
+	expect("primera página", page(model.OrderQuery{Limit: 3}), "ORD-PAGE-A", "ORD-PAGE-B", "ORD-PAGE-C")
+	expect("página siguiente", page(model.OrderQuery{Limit: 3, After: &model.OrderCursor{Value: base.Add(2 * time.Minute), OrderID: "ORD-PAGE-C"}}),
+		"ORD-PAGE-D", "ORD-PAGE-E")
+	expect("descendente", page(model.OrderQuery{Limit: 3, Desc: true}), "ORD-PAGE-E", "ORD-PAGE-D", "ORD-PAGE-C")
+	expect("descendente, página siguiente", page(model.OrderQuery{Limit: 3, Desc: true, After: &model.OrderCursor{Value: base.Add(2 * time.Minute), OrderID: "ORD-PAGE-D"}}),
+		"ORD-PAGE-C", "ORD-PAGE-B", "ORD-PAGE-A")
+	expect("por usuario", page(model.OrderQuery{Limit: 10, UserID: "user-2"}), "ORD-PAGE-B", "ORD-PAGE-D")
+	expect("por estado", page(model.OrderQuery{Limit: 10, Statuses: []string{"Enviado"}}), "ORD-PAGE-C", "ORD-PAGE-E")
+	from, to := base.Add(time.Minute), base.Add(3*time.Minute)
+	expect("por fecha [desde, hasta)", page(model.OrderQuery{Limit: 10, CreatedFrom: &from, CreatedTo: &to}),
+		"ORD-PAGE-B", "ORD-PAGE-C", "ORD-PAGE-D")
+	expect("por workflow", page(model.OrderQuery{Limit: 10, Workflow: "digital"}))
+}
+
+func testShipmentsAndReturns(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	o := &model.OrderStatus{
+		OrderID:  "ORD-SHIP",
+		UserID:   "user-1",
+		Workflow: model.DefaultWorkflow,
+		Status:   "En Preparación",
+		Version:  1,
+		History:  []model.StatusRecord{record("En Preparación")},
+		Items: []model.LineItem{
+			{ArticleID: "A1", Quantity: 1, Status: "Empaquetado", History: []model.StatusRecord{record("Empaquetado")}},
+			{ArticleID: "A2", Quantity: 2, Status: "Pendiente", History: []model.StatusRecord{record("Pendiente")}},
+		},
+		CreatedAt: now,
+	}
+	saveOrder(t, repo, o)
+
+	shipment := model.Shipment{ShipmentID: "S1", Articles: []string{"A1"}, Status: "Enviado", History: []model.StatusRecord{record("Enviado")}, CreatedAt: now}
+	if err := repo.AddShipment(ctx, o.OrderID, 1, shipment, "Enviado", record("Enviado")); err != nil {
+		t.Fatal(err)
+	}
+	// Con la versión vieja: otra escritura (este mismo envío) cambió la orden
+	shipment.ShipmentID = "S2"
+	if err := repo.AddShipment(ctx, o.OrderID, 1, shipment, "Enviado", record("Enviado")); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("AddShipment con versión vieja: se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+	if err := repo.AddShipment(ctx, "ORD-NO-EXISTE", 1, shipment, "Enviado", record("Enviado")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("AddShipment de una orden inexistente: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+	if err := repo.UpdateShipmentStatus(ctx, o.OrderID, "S1", []string{"A1"}, "Enviado", "Entregado", record("Entregado")); err != nil {
+		t.Fatal(err)
+	}
+	// El paquete ya no está en "Enviado": otra operación lo cambió antes
+	if err := repo.UpdateShipmentStatus(ctx, o.OrderID, "S1", []string{"A1"}, "Enviado", "Entregado", record("Entregado")); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("UpdateShipmentStatus desde un estado viejo: se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+	if err := repo.UpdateShipmentStatus(ctx, o.OrderID, "S9", []string{"A1"}, "Enviado", "Entregado", record("Entregado")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateShipmentStatus de un envío inexistente: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+	if err := repo.UpdateItemStatus(ctx, o.OrderID, "A2", "Pendiente", "Cancelado", record("Cancelado")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateItemStatus(ctx, o.OrderID, "A2", "Pendiente", "Empaquetado", record("Empaquetado")); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("UpdateItemStatus desde un estado viejo: se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+	if err := repo.UpdateItemStatus(ctx, o.OrderID, "A9", "Pendiente", "Cancelado", record("Cancelado")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateItemStatus de un artículo inexistente: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+	ret := model.OrderReturn{ReturnID: "R1", Reason: "Llegó roto", Status: "Solicitada", History: []model.StatusRecord{record("Solicitada")}, CreatedAt: now, UpdatedAt: now}
+	if err := repo.AddReturn(ctx, o.OrderID, ret); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateReturnStatus(ctx, o.OrderID, "R1", "Solicitada", "Aprobada", record("Aprobada")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateReturnStatus(ctx, o.OrderID, "R1", "Solicitada", "Rechazada", record("Rechazada")); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("UpdateReturnStatus desde un estado viejo: se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+	if err := repo.UpdateReturnStatus(ctx, o.OrderID, "R9", "Solicitada", "Aprobada", record("Aprobada")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateReturnStatus de una devolución inexistente: se esperaba ErrNotFound, se obtuvo %v", err)
+	}
+
+	got, err := repo.FindByOrderID(ctx, o.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if got.Version != 6 || len(got.History) != 1 {
+		t.Fatalf("se esperaba versión 6 y el historial de la orden sin cambios; se obtuvo %d y %d registros", got.Version, len(got.History))
+	}
+	if len(got.Shipments) != 1 || got.Shipments[0].Status != "Entregado" || len(got.Shipments[0].History) != 2 {
+		t.Fatalf("envíos: %+v", got.Shipments)
+	}
+	if it := got.Items[0]; it.Status != "Entregado" || len(it.History) != 3 {
+		t.Fatalf("artículo A1: %q con %d registros", it.Status, len(it.History))
+	}
+	if it := got.Items[1]; it.Status != "Cancelado" || len(it.History) != 2 {
+		t.Fatalf("artículo A2: %q con %d registros", it.Status, len(it.History))
+	}
+	if len(got.Returns) != 1 || got.Returns[0].Status != "Aprobada" || len(got.Returns[0].History) != 2 {
+		t.Fatalf("devoluciones: %+v", got.Returns)
+	}
+
+	withReturn, err := repo.FindPage(ctx, model.OrderQuery{ReturnStatus: "Aprobada", SortBy: model.SortCreatedAt, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withReturn) != 1 || withReturn[0].OrderID != o.OrderID {
+		t.Fatalf("FindPage por estado de devolución: %d órdenes", len(withReturn))
+	}
+}
+
+func testCountHeld(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	newOrder(t, repo, "ORD-COUNT-1")
+	held := newOrder(t, repo, "ORD-COUNT-2")
+
+	hold := record("En Espera")
+	hold.HeldFrom = "Pendiente"
+	if err := repo.UpdateStatus(ctx, held.OrderID, 1, "En Espera", time.Now(), hold); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(name string, fn func(context.Context, string, string) (int64, error), status string, want int64) {
+		t.Helper()
+		n, err := fn(ctx, model.DefaultWorkflow, status)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n != want {
+			t.Fatalf("%s(%q): se esperaban %d órdenes, se obtuvieron %d", name, status, want, n)
+		}
+	}
+	count("CountByStatus", repo.CountByStatus, "Pendiente", 1)
+	count("CountHeldFrom", repo.CountHeldFrom, "Pendiente", 1)
+	count("CountHeldFrom", repo.CountHeldFrom, "Enviado", 0)
+
+	// Al reanudar, el registro de la pausa deja de ser el actual
+	if err := repo.UpdateStatus(ctx, held.OrderID, 2, "Pendiente", time.Now(), record("Pendiente")); err != nil {
+		t.Fatal(err)
+	}
+	count("CountByStatus", repo.CountByStatus, "Pendiente", 2)
+	count("CountHeldFrom", repo.CountHeldFrom, "Pendiente", 0)
+}
+
+func testStaleAndFlags(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	old := newOrder(t, repo, "ORD-STALE-OLD")
+	recent := newOrder(t, repo, "ORD-STALE-RECENT")
+	// El inicio del SLA se guarda en la misma escritura que el cambio de estado
+	if err := repo.UpdateStatus(ctx, old.OrderID, 1, "Pendiente", now.Add(-2*time.Hour), record("Pendiente")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateStatus(ctx, recent.OrderID, 1, "Pendiente", now.Add(-30*time.Minute), record("Pendiente")); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(q model.StaleQuery) []string {
+		t.Helper()
+		q.Workflow, q.Limit = model.DefaultWorkflow, 10
+		if q.Before.IsZero() {
+			q.Before = now.Add(-time.Hour)
+		}
+		orders, err := repo.FindStale(ctx, q)
+		if err != nil {
+			t.Fatalf("FindStale: %v", err)
+		}
+		var ids []string
+		for _, o := range orders {
+			ids = append(ids, o.OrderID)
+		}
+		return ids
+	}
+	stale := func(excludeFlag string) []string {
+		t.Helper()
+		return find(model.StaleQuery{Status: "Pendiente", ExcludeFlag: excludeFlag})
+	}
+	if got := stale(service.FlagSLAExpired); fmt.Sprint(got) != "[ORD-STALE-OLD]" {
+		t.Fatalf("FindStale: se esperaba [ORD-STALE-OLD], se obtuvo %v", got)
+	}
+
+	flag := model.OrderFlag{Code: service.FlagSLAExpired, Status: "Pendiente", Reason: "SLA vencido", Timestamp: now}
+	for i := 0; i < 2; i++ {
+		if err := repo.AddFlag(ctx, old.OrderID, flag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := repo.FindByOrderID(ctx, old.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Flags) != 1 || got.Version != 3 {
+		t.Fatalf("se esperaba una alerta y versión 3; se obtuvo %d y %d", len(got.Flags), got.Version)
+	}
+	if got := stale(service.FlagSLAExpired); len(got) != 0 {
+		t.Fatalf("FindStale no excluyó la orden con alerta: %v", got)
+	}
+	if got := stale(""); fmt.Sprint(got) != "[ORD-STALE-OLD]" {
+		t.Fatalf("FindStale sin excluir: se esperaba [ORD-STALE-OLD], se obtuvo %v", got)
+	}
+
+	// Las dos entraron a "Enviado" hace dos horas, pero una tuvo una novedad después
+	// de cut (Save pone updated_at): por sla_since vencieron las dos, por updated_at
+	// sólo la que no cambió.
+	shipped := func(id string) {
+		saveOrder(t, repo, &model.OrderStatus{
+			OrderID:   id,
+			UserID:    "user-1",
+			Workflow:  model.DefaultWorkflow,
+			Status:    "Enviado",
+			Version:   1,
+			SLASince:  now.Add(-2 * time.Hour),
+			History:   []model.StatusRecord{{Status: "Enviado", UserID: "admin-1", Timestamp: now.Add(-2 * time.Hour), Current: true}},
+			CreatedAt: now.Add(-3 * time.Hour),
+		})
+	}
+	shipped("ORD-STALE-QUIET")
+	time.Sleep(5 * time.Millisecond)
+	cut := time.Now().UTC()
+	time.Sleep(5 * time.Millisecond)
+	shipped("ORD-STALE-TOUCHED")
+	if got := find(model.StaleQuery{Status: "Enviado", Before: cut}); fmt.Sprint(got) != "[ORD-STALE-QUIET ORD-STALE-TOUCHED]" {
+		t.Fatalf("FindStale por sla_since: se obtuvo %v", got)
+	}
+	if got := find(model.StaleQuery{Status: "Enviado", Before: cut, ByUpdate: true}); fmt.Sprint(got) != "[ORD-STALE-QUIET]" {
+		t.Fatalf("FindStale por updated_at: se obtuvo %v", got)
+	}
+}
+
+func testScanAndReplace(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	for _, id := range []string{"ORD-SCAN-2", "ORD-SCAN-3", "ORD-SCAN-1"} {
+		newOrder(t, repo, id)
+	}
+
+	// ReplaceHistory dentro de Scan, como la reparación del chequeo de consistencia
+	history := []model.StatusRecord{record("Pendiente"), record("En Preparación")}
+	history[0].Current = false
+	var seen []string
+	err := repo.Scan(ctx, func(o *model.OrderStatus) error {
+		seen = append(seen, o.OrderID)
+		if o.OrderID == "ORD-SCAN-2" {
+			return repo.ReplaceHistory(ctx, o.OrderID, o.Version, "En Preparación", history)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(seen) != "[ORD-SCAN-1 ORD-SCAN-2 ORD-SCAN-3]" {
+		t.Fatalf("Scan: se esperaba el orden por order_id, se obtuvo %v", seen)
+	}
+
+	got, err := repo.FindByOrderID(ctx, "ORD-SCAN-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, got)
+	if got.Status != "En Preparación" || got.Version != 2 || len(got.History) != 2 {
+		t.Fatalf("ReplaceHistory: %q, versión %d, %d registros", got.Status, got.Version, len(got.History))
+	}
+	if err := repo.ReplaceHistory(ctx, "ORD-SCAN-2", 1, "Pendiente", history[:1]); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("se esperaba ErrVersionConflict, se obtuvo %v", err)
+	}
+}
+
+func testSearch(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	for _, o := range []struct {
+		id      string
+		address model.Shipping
+	}{
+		{"ORD-SEARCH-1", model.Shipping{AddressLine1: "Belgrano 123", City: "Godoy Cruz", Province: "Mendoza"}},
+		{"ORD-SEARCH-2", model.Shipping{AddressLine1: "San Martín 50", City: "Mendoza", Province: "Mendoza"}},
+		{"X-1", model.Shipping{AddressLine1: "Godoy 9", City: "Luján de Cuyo", Province: "Mendoza"}},
+		{"cuyo-1", model.Shipping{AddressLine1: "Sarmiento 3", City: "Cuyo", Province: "Mendoza"}},
+	} {
+		saveOrder(t, repo, &model.OrderStatus{
+			OrderID:   o.id,
+			UserID:    "user-1",
+			Workflow:  model.DefaultWorkflow,
+			Status:    "Pendiente",
+			Version:   1,
+			History:   []model.StatusRecord{record("Pendiente")},
+			Shipping:  o.address,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	search := func(text string, limit int) []string {
+		t.Helper()
+		orders, err := repo.Search(ctx, text, limit)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		var ids []string
+		for _, o := range orders {
+			ids = append(ids, o.OrderID)
+		}
+		return ids
+	}
+
+	if got := search("ORD-SEARCH", 10); len(got) != 2 || got[0] != "ORD-SEARCH-1" || got[1] != "ORD-SEARCH-2" {
+		t.Fatalf("por prefijo: se esperaba [ORD-SEARCH-1 ORD-SEARCH-2], se obtuvo %v", got)
+	}
+	if got := search("ORD-SEARCH", 1); len(got) != 1 {
+		t.Fatalf("limit 1: se obtuvo %v", got)
+	}
+	if got := search("belgrano godoy", 10); len(got) != 2 || got[0] != "ORD-SEARCH-1" {
+		t.Fatalf("por texto: se esperaba primero ORD-SEARCH-1 y después X-1, se obtuvo %v", got)
+	}
+	// cuyo-1 aparece por prefijo y otra vez por texto: no le quita lugar a X-1
+	if got := search("cuyo", 2); fmt.Sprint(got) != "[cuyo-1 X-1]" {
+		t.Fatalf("prefijo y texto: se esperaba [cuyo-1 X-1], se obtuvo %v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-status-service-2/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrScheduleNotFound = errors.New("transición programada no encontrada")
+
+// Transiciones programadas (colección scheduled_transitions)
+type MongoScheduleRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoScheduleRepository(db *mongo.Database) *MongoScheduleRepository {
+	return &MongoScheduleRepository{col: db.Collection("scheduled_transitions")}
+}
+
+func (m *MongoScheduleRepository) Save(ctx context.Context, st *model.ScheduledTransition) error {
+	_, err := m.col.InsertOne(ctx, st)
+	return err
+}
+
+func (m *MongoScheduleRepository) FindByID(ctx context.Context, orderID, scheduleID string) (*model.ScheduledTransition, error) {
+	var res model.ScheduledTransition
+	err := m.col.FindOne(ctx, bson.M{"order_id": orderID, "schedule_id": scheduleID}).Decode(&res)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrScheduleNotFound
+	}
+	return &res, err
+}
+
+// FindByOrderID devuelve las transiciones programadas de la orden, por fecha
+func (m *MongoScheduleRepository) FindByOrderID(ctx context.Context, orderID string) ([]*model.ScheduledTransition, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "due_at", Value: 1}})
+	return m.find(ctx, bson.M{"order_id": orderID}, opts)
+}
+
+// FindDue devuelve las pendientes con fecha vencida y las que quedaron en processing
+// con la toma vencida, de la más vieja a la más nueva
+func (m *MongoScheduleRepository) FindDue(ctx context.Context, now, staleBefore time.Time, limit int64) ([]*model.ScheduledTransition, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"state": model.SchedulePending, "due_at": bson.M{"$lte": now}},
+		bson.M{"state": model.ScheduleProcessing, "claimed_at": staleClaim(staleBefore)},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "due_at", Value: 1}}).SetLimit(limit)
+	return m.find(ctx, filter, opts)
+}
+
+// Claim pasa la transición a processing si está pendiente o si su toma venció.
+// Devuelve false si otro proceso la tiene (o ya no está por aplicarse).
+func (m *MongoScheduleRepository) Claim(ctx context.Context, scheduleID string, staleBefore time.Time) (bool, error) {
+	filter := bson.M{
+		"schedule_id": scheduleID,
+		"$or": bson.A{
+			bson.M{"state": model.SchedulePending},
+			bson.M{"state": model.ScheduleProcessing, "claimed_at": staleClaim(staleBefore)},
+		},
+	}
+	res, err := m.col.UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{"state": model.ScheduleProcessing, "claimed_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// staleClaim matchea una toma anterior a staleBefore; las tomadas antes de que
+// existiera claimed_at también cuentan como vencidas.
+func staleClaim(staleBefore time.Time) bson.M {
+	return bson.M{"$not": bson.M{"$gte": staleBefore}}
+}
+
+// FindPendingByStatus devuelve las que todavía no se aplicaron (pendientes o en proceso) hacia ese estado
+func (m *MongoScheduleRepository) FindPendingByStatus(ctx context.Context, status string) ([]*model.ScheduledTransition, error) {
+	filter := bson.M{
+		"status": status,
+		"state":  bson.M{"$in": bson.A{model.SchedulePending, model.ScheduleProcessing}},
+	}
+	return m.find(ctx, filter, options.Find())
+}
+
+// UpdateState pasa la transición de "from" a "to" sólo si sigue en "from".
+// Devuelve false si otro proceso la cambió antes.
+func (m *MongoScheduleRepository) UpdateState(ctx context.Context, scheduleID, from, to, result string) (bool, error) {
+	now := time.Now().UTC()
+	res, err := m.col.UpdateOne(ctx,
+		bson.M{"schedule_id": scheduleID, "state": from},
+		bson.M{"$set": bson.M{"state": to, "result": result, "processed_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (m *MongoScheduleRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*model.ScheduledTransition, error) {
+	cur, err := m.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*model.ScheduledTransition
+	for cur.Next(ctx) {
+		var v model.ScheduledTransition
+		if err := cur.Decode(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, &v)
+	}
+	return out, nil
+}
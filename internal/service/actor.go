@@ -0,0 +1,59 @@
+package service
+
+import "slices"
+
+// Permisos del servicio de auth que dan cada rol. "user" no se asigna por
+// permiso: lo tiene quien es dueño de la orden.
+var rolePermissions = map[string]string{
+	"admin":                  RoleAdmin,
+	"order_status.warehouse": RoleWarehouse,
+	"order_status.courier":   RoleCourier,
+	"order_status.support":   RoleSupport,
+}
+
+// Roles que pueden ver cualquier orden (además del dueño)
+var readAllRoles = []string{RoleAdmin, RoleSupport, RoleWarehouse}
+
+// Roles que pueden poner una orden en espera y reanudarla
+var holdRoles = []string{RoleAdmin, RoleSupport}
+
+// Actor es quien realiza una operación, con los roles que le dan sus permisos.
+type Actor struct {
+	ID    string
+	Roles []string
+}
+
+// Actor de las transiciones automáticas (SLA, envíos entregados): usa las transiciones de admin
+var SystemActor = Actor{ID: SystemActorID, Roles: []string{RoleAdmin}}
+
+// ActorFromPermissions arma el actor a partir de los permisos que devuelve el servicio de auth.
+// Los permisos que no corresponden a ningún rol se ignoran.
+func ActorFromPermissions(id string, permissions []string) Actor {
+	a := Actor{ID: id}
+	for _, p := range permissions {
+		if role, ok := rolePermissions[p]; ok && !a.Has(role) {
+			a.Roles = append(a.Roles, role)
+		}
+	}
+	return a
+}
+
+// Has indica si el actor tiene el rol
+func (a Actor) Has(role string) bool {
+	return slices.Contains(a.Roles, role)
+}
+
+// HasAny indica si el actor tiene alguno de los roles
+func (a Actor) HasAny(roles ...string) bool {
+	for _, r := range roles {
+		if a.Has(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanReadAllOrders indica si el actor puede ver órdenes de otros usuarios
+func (a Actor) CanReadAllOrders() bool {
+	return a.HasAny(readAllRoles...)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Máximo de órdenes por pedido de actualización masiva
+const maxBulkOrders = 1000
+
+const defaultBulkConcurrency = 10
+
+var (
+	ErrBulkNoTargets = errors.New("hay que indicar orderIds o un filtro por estado")
+	ErrBulkTooLarge  = fmt.Errorf("no se pueden actualizar más de %d órdenes por pedido", maxBulkOrders)
+)
+
+// BulkResult es el resultado de una orden dentro de una actualización masiva (Err nil = ok)
+type BulkResult struct {
+	OrderID string
+	Err     error
+}
+
+// BulkUpdateStatus aplica la misma transición a varias órdenes, cada una con las
+// validaciones de UpdateStatus, de a policy.BulkConcurrency en paralelo.
+// Con req.DryRun sólo valida. Los resultados respetan el orden de las órdenes.
+func (s *OrderStatusService) BulkUpdateStatus(ctx context.Context, req dto.BulkStatusRequest, actor Actor) ([]BulkResult, error) {
+	ids, err := s.bulkTargets(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := s.policy.BulkConcurrency
+	if workers <= 0 {
+		workers = defaultBulkConcurrency
+	}
+
+	update := dto.UpdateStatusRequest{Status: req.Status, Reason: req.Reason, Data: req.Data}
+	results := make([]BulkResult, len(ids))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = BulkResult{
+				OrderID: id,
+				Err:     s.updateStatus(ctx, id, update, actor, req.DryRun),
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// bulkTargets arma la lista de órdenes (sin repetidos) a partir de orderIds o del filtro
+func (s *OrderStatusService) bulkTargets(ctx context.Context, req dto.BulkStatusRequest) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range req.OrderIDs {
+		add(id)
+	}
+
+	if req.Filter != nil && req.Filter.Status != "" {
+		// Uno más que el máximo alcanza para saber si el filtro se pasa
+		orders, err := s.repo.FindPage(ctx, model.OrderQuery{
+			Statuses: []string{req.Filter.Status},
+			Workflow: req.Filter.Workflow,
+			SortBy:   model.SortCreatedAt,
+			Limit:    maxBulkOrders + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range orders {
+			add(o.OrderID)
+		}
+	} else if len(req.OrderIDs) == 0 {
+		return nil, ErrBulkNoTargets
+	}
+
+	if len(ids) > maxBulkOrders {
+		return nil, ErrBulkTooLarge
+	}
+	return ids, nil
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+var rejected = dto.TransitionDataDTO{ReasonCode: "OUT_OF_STOCK"}
+
+func TestBulkUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        dto.BulkStatusRequest
+		wantErr    error
+		wantOrders []string // resultados en este orden
+		wantFailed map[string]error
+		wantStatus map[string]string // estado de cada orden después del pedido
+	}{
+		{
+			name:       "por ids, sin repetidos y en el orden pedido",
+			req:        dto.BulkStatusRequest{OrderIDs: []string{"B-3", "B-1", "B-3", "NOPE"}, Status: "Rechazado", Data: rejected},
+			wantOrders: []string{"B-3", "B-1", "NOPE"},
+			wantFailed: map[string]error{"NOPE": repository.ErrNotFound},
+			wantStatus: map[string]string{"B-1": "Rechazado", "B-2": "En Preparación", "B-3": "Rechazado"},
+		},
+		{
+			name:       "por filtro",
+			req:        dto.BulkStatusRequest{Filter: &dto.BulkFilterDTO{Status: "Pendiente"}, Status: "En Preparación"},
+			wantOrders: []string{"B-1", "B-3"},
+			wantStatus: map[string]string{"B-1": "En Preparación", "B-2": "En Preparación", "B-3": "En Preparación"},
+		},
+		{
+			name:       "ids primero y después el filtro",
+			req:        dto.BulkStatusRequest{OrderIDs: []string{"B-2", "B-3"}, Filter: &dto.BulkFilterDTO{Status: "Pendiente"}, Status: "Rechazado", Data: rejected},
+			wantOrders: []string{"B-2", "B-3", "B-1"},
+			wantStatus: map[string]string{"B-1": "Rechazado", "B-2": "Rechazado", "B-3": "Rechazado"},
+		},
+		{
+			name:       "transición inválida para una",
+			req:        dto.BulkStatusRequest{OrderIDs: []string{"B-1", "B-2"}, Status: "Enviado", Data: shippedData},
+			wantOrders: []string{"B-1", "B-2"},
+			wantFailed: map[string]error{"B-1": service.ErrInvalidTransition},
+			wantStatus: map[string]string{"B-1": "Pendiente", "B-2": "Enviado", "B-3": "Pendiente"},
+		},
+		{
+			name:       "dry run no cambia nada",
+			req:        dto.BulkStatusRequest{OrderIDs: []string{"B-1", "B-2"}, Status: "Enviado", Data: shippedData, DryRun: true},
+			wantOrders: []string{"B-1", "B-2"},
+			wantFailed: map[string]error{"B-1": service.ErrInvalidTransition},
+			wantStatus: map[string]string{"B-1": "Pendiente", "B-2": "En Preparación", "B-3": "Pendiente"},
+		},
+		{
+			name:    "sin órdenes",
+			req:     dto.BulkStatusRequest{Filter: &dto.BulkFilterDTO{}, Status: "En Preparación"},
+			wantErr: service.ErrBulkNoTargets,
+		},
+		{
+			name:    "demasiadas órdenes",
+			req:     dto.BulkStatusRequest{OrderIDs: manyIDs(1001), Status: "En Preparación"},
+			wantErr: service.ErrBulkTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			for _, id := range []string{"B-1", "B-2", "B-3"} {
+				initOrder(t, svc, id)
+			}
+			moveTo(t, svc, "B-2", dto.UpdateStatusRequest{Status: "En Preparación"})
+
+			results, err := svc.BulkUpdateStatus(ctx, tt.req, admin)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if len(results) != len(tt.wantOrders) {
+				t.Fatalf("se esperaban %d resultados, se obtuvo %d", len(tt.wantOrders), len(results))
+			}
+			for i, r := range results {
+				if r.OrderID != tt.wantOrders[i] {
+					t.Fatalf("resultado %d: se esperaba %s, se obtuvo %s", i, tt.wantOrders[i], r.OrderID)
+				}
+				if want := tt.wantFailed[r.OrderID]; !errors.Is(r.Err, want) {
+					t.Fatalf("%s: se esperaba %v, se obtuvo %v", r.OrderID, want, r.Err)
+				}
+			}
+			for id, want := range tt.wantStatus {
+				ord, err := orders.FindByOrderID(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ord.Status != want {
+					t.Fatalf("%s: se esperaba %q, se obtuvo %q", id, want, ord.Status)
+				}
+			}
+		})
+	}
+}
+
+func manyIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("ORD-%04d", i)
+	}
+	return ids
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Interfaz que debe implementar el repositorio del catálogo de estados
+type StateCatalogRepository interface {
+	FindAll(ctx context.Context) ([]*model.CatalogState, error)
+	Save(ctx context.Context, st *model.CatalogState) error
+	Delete(ctx context.Context, workflow, name string) error
+	AssignDefaultWorkflow(ctx context.Context) error
+}
+
+var (
+	ErrStateNotFound       = errors.New("estado no encontrado")
+	ErrStateAlreadyExists  = errors.New("el estado ya existe en el catálogo")
+	ErrStateInUse          = errors.New("hay órdenes en ese estado (o en espera para volver a él), no se puede eliminar")
+	ErrInvalidState        = errors.New("nombre de estado inválido")
+	ErrUnknownRole         = errors.New("rol desconocido")
+	ErrUnknownField        = errors.New("dato requerido desconocido")
+	ErrUnknownWorkflow     = errors.New("workflow desconocido")
+	ErrTransitionNotFound  = errors.New("transición no encontrada")
+	ErrTransitionExists    = errors.New("la transición ya existe")
+	ErrFinalStateHasOutput = errors.New("un estado final no puede tener transiciones de salida")
+	ErrInitialStateDelete  = errors.New("no se puede eliminar el estado inicial del workflow")
+	ErrStateReferenced     = errors.New("el estado todavía está referenciado, no se puede eliminar")
+	ErrStateUnreachable    = errors.New("el cambio deja estados inalcanzables desde el inicial")
+)
+
+// Servicio de administración del catálogo de estados y transiciones
+type StateCatalogService struct {
+	repo      StateCatalogRepository
+	orders    OrderRepository
+	schedules ScheduleRepository
+}
+
+func NewStateCatalogService(r StateCatalogRepository, orders OrderRepository, schedules ScheduleRepository) *StateCatalogService {
+	return &StateCatalogService{repo: r, orders: orders, schedules: schedules}
+}
+
+// Seed sincroniza state_catalog con los workflows versionados sin pisar lo hecho en
+// runtime: agrega los estados que falten y actualiza los existentes que nunca se
+// editaron con la API del catálogo (inicial, final, transiciones, datos requeridos,
+// SLA...). Los estados editados en runtime y los que ya no están en el archivo se
+// dejan como están y sólo se loguean: nunca impide arrancar el servicio.
+func (s *StateCatalogService) Seed(ctx context.Context, file *WorkflowFile) error {
+	if err := s.repo.AssignDefaultWorkflow(ctx); err != nil {
+		return err
+	}
+
+	states, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	defs := file.CatalogStates()
+	defined := map[string]bool{}
+	added, updated := 0, 0
+	var edited []string
+	for _, def := range defs {
+		defined[def.Workflow+"/"+def.Name] = true
+		cur, ok := NewWorkflow(def.Workflow, states).states[def.Name]
+		if !ok {
+			if err := s.repo.Save(ctx, def); err != nil {
+				return err
+			}
+			added++
+			continue
+		}
+		if cur.RuntimeEdited {
+			edited = append(edited, def.Workflow+"/"+def.Name)
+			continue
+		}
+		if syncFromDefinition(cur, def) {
+			if err := s.repo.Save(ctx, cur); err != nil {
+				return err
+			}
+			updated++
+		}
+	}
+	var extra []string
+	for _, st := range states {
+		if key := workflowName(st.Workflow) + "/" + st.Name; !defined[key] {
+			extra = append(extra, key)
+		}
+	}
+
+	if added+updated > 0 {
+		log.Printf("Catálogo de estados sincronizado con el workflow: %d agregados, %d actualizados", added, updated)
+	}
+	if len(edited) > 0 {
+		log.Printf("Catálogo de estados: se conservan los estados editados en runtime: %s", strings.Join(edited, ", "))
+	}
+	if len(extra) > 0 {
+		log.Printf("⚠ Estados del catálogo que no están en el workflow (se conservan): %s", strings.Join(extra, ", "))
+	}
+	return nil
+}
+
+// syncFromDefinition copia en cur la definición del archivo. Devuelve true si hubo cambios.
+func syncFromDefinition(cur, def *model.CatalogState) bool {
+	changed := cur.Initial != def.Initial ||
+		cur.Final != def.Final ||
+		cur.NotifiesCustomer != def.NotifiesCustomer ||
+		cur.Returnable != def.Returnable ||
+		!slices.Equal(cur.Requires, def.Requires) ||
+		!slices.Equal(cur.ItemsRequire, def.ItemsRequire) ||
+		!reflect.DeepEqual(cur.Timeout, def.Timeout) ||
+		!sameTransitions(cur.Transitions, def.Transitions)
+	if !changed {
+		return false
+	}
+	cur.Initial = def.Initial
+	cur.Final = def.Final
+	cur.NotifiesCustomer = def.NotifiesCustomer
+	cur.Returnable = def.Returnable
+	cur.Requires = def.Requires
+	cur.ItemsRequire = def.ItemsRequire
+	cur.Timeout = def.Timeout
+	cur.Transitions = def.Transitions
+	return true
+}
+
+// sameTransitions compara las transiciones por rol (un rol sin destinos cuenta como ausente)
+func sameTransitions(a, b map[string][]string) bool {
+	for role := range a {
+		if !slices.Equal(a[role], b[role]) {
+			return false
+		}
+	}
+	for role := range b {
+		if !slices.Equal(a[role], b[role]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Workflow devuelve el grafo vigente del workflow indicado según lo guardado en el catálogo
+func (s *StateCatalogService) Workflow(ctx context.Context, name string) (*Workflow, error) {
+	states, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wf := NewWorkflow(workflowName(name), states)
+	if wf.IsEmpty() {
+		return nil, ErrUnknownWorkflow
+	}
+	return wf, nil
+}
+
+// GetStates lista el catálogo; si workflow no está vacío, sólo los estados de ese workflow
+func (s *StateCatalogService) GetStates(ctx context.Context, workflow string) ([]*model.CatalogState, error) {
+	states, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if workflow == "" {
+		return states, nil
+	}
+
+	out := []*model.CatalogState{}
+	for _, st := range states {
+		if workflowName(st.Workflow) == workflow {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+func (s *StateCatalogService) findState(ctx context.Context, workflow, name string) (*model.CatalogState, *Workflow, error) {
+	wf, err := s.Workflow(ctx, workflow)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, ok := wf.states[name]
+	if !ok {
+		return nil, wf, ErrStateNotFound
+	}
+	return st, wf, nil
+}
+
+func (s *StateCatalogService) CreateState(ctx context.Context, req dto.CreateStateRequest) (*model.CatalogState, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || name == OnHoldStatus {
+		return nil, ErrInvalidState
+	}
+
+	_, wf, err := s.findState(ctx, req.Workflow, name)
+	if err == nil {
+		return nil, ErrStateAlreadyExists
+	}
+	if !errors.Is(err, ErrStateNotFound) {
+		return nil, err
+	}
+
+	if err := validateRequires(req.Requires); err != nil {
+		return nil, err
+	}
+
+	st := &model.CatalogState{
+		Workflow:      wf.Name,
+		Name:          name,
+		Final:         req.Final,
+		Requires:      nonNil(req.Requires),
+		Transitions:   map[string][]string{},
+		RuntimeEdited: true,
+	}
+	return st, s.repo.Save(ctx, st)
+}
+
+func (s *StateCatalogService) UpdateState(ctx context.Context, workflow, name string, req dto.UpdateStateRequest) (*model.CatalogState, error) {
+	st, wf, err := s.findState(ctx, workflow, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRequires(req.Requires); err != nil {
+		return nil, err
+	}
+
+	before := wf.reachable()
+	st.Final = req.Final
+	st.Requires = nonNil(req.Requires)
+	if err := checkGraph(before, wf); err != nil {
+		return nil, err
+	}
+	st.RuntimeEdited = true
+	return st, s.repo.Save(ctx, st)
+}
+
+// DeleteState elimina un estado del workflow, siempre que ninguna orden esté en él
+// y que nada lo siga referenciando (ver references).
+func (s *StateCatalogService) DeleteState(ctx context.Context, workflow, name string) error {
+	st, wf, err := s.findState(ctx, workflow, name)
+	if err != nil {
+		return err
+	}
+	if st.Initial {
+		return ErrInitialStateDelete
+	}
+
+	n, err := s.ordersIn(ctx, wf.Name, name)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrStateInUse
+	}
+
+	refs, err := s.references(ctx, wf, name)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return fmt.Errorf("%w: %s", ErrStateReferenced, strings.Join(refs, "; "))
+	}
+
+	before := wf.reachable()
+	delete(wf.states, name)
+	if err := checkGraph(before, wf); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, st.Workflow, name)
+}
+
+// ordersIn cuenta las órdenes que están en el estado o que, estando en espera,
+// vuelven a él al reanudarse.
+func (s *StateCatalogService) ordersIn(ctx context.Context, workflow, name string) (int64, error) {
+	n, err := s.orders.CountByStatus(ctx, workflow, name)
+	if err != nil {
+		return 0, err
+	}
+	held, err := s.orders.CountHeldFrom(ctx, workflow, name)
+	if err != nil {
+		return 0, err
+	}
+	return n + held, nil
+}
+
+// references describe lo que todavía apunta al estado: transiciones de otros estados,
+// reglas de SLA que pasan a él y transiciones programadas pendientes hacia él.
+// Si se borrara, esas transiciones fallarían (el scheduler de SLA, en cada ciclo).
+func (s *StateCatalogService) references(ctx context.Context, wf *Workflow, name string) ([]string, error) {
+	var refs []string
+	for _, other := range wf.states {
+		for role, targets := range other.Transitions {
+			if contains(targets, name) {
+				refs = append(refs, fmt.Sprintf("transición de %s desde %q", role, other.Name))
+			}
+		}
+		if other.Timeout != nil && other.Timeout.Action == TimeoutTransition && other.Timeout.To == name {
+			refs = append(refs, fmt.Sprintf("regla de SLA de %q", other.Name))
+		}
+	}
+	slices.Sort(refs) // wf.states es un map: orden estable en el mensaje
+
+	scheduled, err := s.schedules.FindPendingByStatus(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scheduled {
+		ord, err := s.orders.FindByOrderID(ctx, sc.OrderID)
+		if err != nil {
+			continue // la orden ya no existe: la transición se va a omitir
+		}
+		if workflowName(ord.Workflow) == wf.Name {
+			refs = append(refs, fmt.Sprintf("transición programada %s de la orden %s", sc.ScheduleID, sc.OrderID))
+		}
+	}
+	return refs, nil
+}
+
+func (s *StateCatalogService) GetTransitions(ctx context.Context, workflow string) ([]dto.TransitionDTO, error) {
+	states, err := s.GetStates(ctx, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []dto.TransitionDTO{}
+	for _, st := range states {
+		for role, targets := range st.Transitions {
+			for _, to := range targets {
+				out = append(out, dto.TransitionDTO{Workflow: workflowName(st.Workflow), From: st.Name, To: to, Role: role})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *StateCatalogService) AddTransition(ctx context.Context, t dto.TransitionDTO) error {
+	if !isKnownRole(t.Role) {
+		return ErrUnknownRole
+	}
+	if t.From == t.To {
+		return ErrInvalidTransition
+	}
+
+	from, wf, err := s.findState(ctx, t.Workflow, t.From)
+	if err != nil {
+		return err
+	}
+	if !wf.IsValidState(t.To) {
+		return ErrStateNotFound
+	}
+	if contains(from.Transitions[t.Role], t.To) {
+		return ErrTransitionExists
+	}
+
+	before := wf.reachable()
+	if from.Transitions == nil {
+		from.Transitions = map[string][]string{}
+	}
+	from.Transitions[t.Role] = append(from.Transitions[t.Role], t.To)
+	if err := checkGraph(before, wf); err != nil {
+		return err
+	}
+	from.RuntimeEdited = true
+	return s.repo.Save(ctx, from)
+}
+
+func (s *StateCatalogService) RemoveTransition(ctx context.Context, t dto.TransitionDTO) error {
+	from, wf, err := s.findState(ctx, t.Workflow, t.From)
+	if err != nil {
+		return err
+	}
+	if !contains(from.Transitions[t.Role], t.To) {
+		return ErrTransitionNotFound
+	}
+
+	before := wf.reachable()
+	from.Transitions[t.Role] = slices.DeleteFunc(from.Transitions[t.Role], func(v string) bool { return v == t.To })
+	if err := checkGraph(before, wf); err != nil {
+		return err
+	}
+	from.RuntimeEdited = true
+	return s.repo.Save(ctx, from)
+}
+
+// checkGraph valida el grafo vigente después de una edición, con las reglas del
+// archivo: ningún estado final con salidas y ningún estado alcanzable desde el
+// inicial (before) deja de serlo. Un estado recién creado todavía no tiene
+// transiciones de entrada, así que no se exige que todos sean alcanzables.
+func checkGraph(before map[string]bool, wf *Workflow) error {
+	for _, st := range wf.states {
+		if st.Final && hasTransitions(st) {
+			return ErrFinalStateHasOutput
+		}
+	}
+	after := wf.reachable()
+	var lost []string
+	for name := range before {
+		if wf.IsValidState(name) && !after[name] {
+			lost = append(lost, name)
+		}
+	}
+	if len(lost) > 0 {
+		slices.Sort(lost)
+		return fmt.Errorf("%w: %s", ErrStateUnreachable, strings.Join(lost, ", "))
+	}
+	return nil
+}
+
+func hasTransitions(st *model.CatalogState) bool {
+	for _, targets := range st.Transitions {
+		if len(targets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRequires(fields []string) error {
+	for _, f := range fields {
+		if !isKnownField(f) {
+			return ErrUnknownField
+		}
+	}
+	return nil
+}
+
+// nonNil guarda [] en lugar de null
+func nonNil(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"order-status-service-2/internal/model"
+)
+
+// Códigos de las inconsistencias que detecta ConsistencyChecker
+const (
+	ViolationEmptyHistory     = "empty_history"      // la orden no tiene historial
+	ViolationNoCurrent        = "no_current"         // ningún registro marcado como actual
+	ViolationMultipleCurrent  = "multiple_current"   // más de un registro actual
+	ViolationStatusMismatch   = "status_mismatch"    // status no coincide con el registro actual
+	ViolationCurrentNotLatest = "current_not_latest" // el registro actual no es el más reciente
+	ViolationOutOfOrder       = "history_out_of_order"
+)
+
+// Acceso a order_statuses que necesita el chequeo (lo implementa MongoOrderRepository)
+type ConsistencyRepository interface {
+	Scan(ctx context.Context, fn func(*model.OrderStatus) error) error
+	ReplaceHistory(ctx context.Context, orderID string, expectedVersion int64, status string, history []model.StatusRecord) error
+}
+
+type Violation struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// OrderConsistency son las inconsistencias de una orden y, si se pidió reparar, el resultado
+type OrderConsistency struct {
+	OrderID    string      `json:"orderId"`
+	Violations []Violation `json:"violations"`
+	Fixed      bool        `json:"fixed"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// ConsistencyReport es el reporte en JSON que se archiva después de cada corrida
+type ConsistencyReport struct {
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt"`
+	Fix        bool               `json:"fix"`
+	Scanned    int                `json:"scanned"`
+	Invalid    int                `json:"invalid"`
+	Fixed      int                `json:"fixed"`
+	Failed     int                `json:"failed"`
+	Orders     []OrderConsistency `json:"orders"`
+}
+
+// ConsistencyChecker revisa los invariantes de historial de todas las órdenes:
+// exactamente un registro actual, que sea el más reciente y coincida con status.
+type ConsistencyChecker struct {
+	repo ConsistencyRepository
+}
+
+func NewConsistencyChecker(repo ConsistencyRepository) *ConsistencyChecker {
+	return &ConsistencyChecker{repo: repo}
+}
+
+// Run recorre order_statuses y devuelve las órdenes con inconsistencias. Con fix,
+// además las repara ordenando el historial por timestamp: el último registro
+// queda como actual y define el status.
+func (c *ConsistencyChecker) Run(ctx context.Context, fix bool) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{StartedAt: time.Now().UTC(), Fix: fix, Orders: []OrderConsistency{}}
+
+	err := c.repo.Scan(ctx, func(o *model.OrderStatus) error {
+		report.Scanned++
+		violations := CheckOrder(o)
+		if len(violations) == 0 {
+			return nil
+		}
+		report.Invalid++
+
+		res := OrderConsistency{OrderID: o.OrderID, Violations: violations}
+		if fix {
+			if err := c.repair(ctx, o); err != nil {
+				res.Error = err.Error()
+				report.Failed++
+			} else {
+				res.Fixed = true
+				report.Fixed++
+			}
+		}
+		report.Orders = append(report.Orders, res)
+		return nil
+	})
+	report.FinishedAt = time.Now().UTC()
+	return report, err
+}
+
+// CheckOrder devuelve las inconsistencias del historial de la orden
+func CheckOrder(o *model.OrderStatus) []Violation {
+	var out []Violation
+	if len(o.History) == 0 {
+		return []Violation{{Code: ViolationEmptyHistory, Detail: fmt.Sprintf("status %q sin historial", o.Status)}}
+	}
+
+	current := -1
+	count := 0
+	latest := 0
+	for i, h := range o.History {
+		if h.Current {
+			count++
+			current = i
+		}
+		if i > 0 && h.Timestamp.Before(o.History[i-1].Timestamp) {
+			out = append(out, Violation{
+				Code:   ViolationOutOfOrder,
+				Detail: fmt.Sprintf("el registro %d (%s) es anterior al %d", i, h.Timestamp.Format(time.RFC3339), i-1),
+			})
+		}
+		if !h.Timestamp.Before(o.History[latest].Timestamp) {
+			latest = i
+		}
+	}
+
+	switch {
+	case count == 0:
+		out = append(out, Violation{Code: ViolationNoCurrent, Detail: "ningún registro marcado como actual"})
+	case count > 1:
+		out = append(out, Violation{Code: ViolationMultipleCurrent, Detail: fmt.Sprintf("%d registros marcados como actuales", count)})
+	}
+	if count == 1 {
+		if o.History[current].Status != o.Status {
+			out = append(out, Violation{
+				Code:   ViolationStatusMismatch,
+				Detail: fmt.Sprintf("status %q pero el registro actual es %q", o.Status, o.History[current].Status),
+			})
+		}
+		if o.History[current].Timestamp.Before(o.History[latest].Timestamp) {
+			out = append(out, Violation{
+				Code:   ViolationCurrentNotLatest,
+				Detail: fmt.Sprintf("el registro actual (%q) no es el más reciente (%q)", o.History[current].Status, o.History[latest].Status),
+			})
+		}
+	}
+	return out
+}
+
+// repair ordena el historial por timestamp (estable), deja como actual sólo el último
+// y alinea status. Si la orden cambió mientras tanto, falla con conflicto de versión.
+func (c *ConsistencyChecker) repair(ctx context.Context, o *model.OrderStatus) error {
+	if len(o.History) == 0 {
+		return errors.New("no se puede reparar una orden sin historial")
+	}
+
+	history := append([]model.StatusRecord(nil), o.History...)
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+	for i := range history {
+		history[i].Current = i == len(history)-1
+	}
+	status := history[len(history)-1].Status
+
+	return c.repo.ReplaceHistory(ctx, o.OrderID, o.Version, status, history)
+}
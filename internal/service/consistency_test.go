@@ -0,0 +1,175 @@
+package service_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+var checkedAt = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// historyRecord arma un registro del historial minutes minutos después de checkedAt
+func historyRecord(status string, minutes int, current bool) model.StatusRecord {
+	return model.StatusRecord{Status: status, Timestamp: checkedAt.Add(time.Duration(minutes) * time.Minute), Current: current}
+}
+
+func TestCheckOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		history []model.StatusRecord
+		want    []string // códigos de las inconsistencias, en orden
+	}{
+		{
+			name:    "consistente",
+			status:  "En Preparación",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("En Preparación", 5, true)},
+		},
+		{
+			name:   "sin historial",
+			status: "Pendiente",
+			want:   []string{service.ViolationEmptyHistory},
+		},
+		{
+			name:    "sin registro actual",
+			status:  "En Preparación",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("En Preparación", 5, false)},
+			want:    []string{service.ViolationNoCurrent},
+		},
+		{
+			name:    "dos registros actuales",
+			status:  "En Preparación",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, true), historyRecord("En Preparación", 5, true)},
+			want:    []string{service.ViolationMultipleCurrent},
+		},
+		{
+			name:    "status distinto del registro actual",
+			status:  "Enviado",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("En Preparación", 5, true)},
+			want:    []string{service.ViolationStatusMismatch},
+		},
+		{
+			name:    "actual fuera de orden",
+			status:  "En Preparación",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("Enviado", 10, false), historyRecord("En Preparación", 5, true)},
+			want:    []string{service.ViolationOutOfOrder, service.ViolationCurrentNotLatest},
+		},
+		{
+			name:    "mismo timestamp",
+			status:  "En Preparación",
+			history: []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("En Preparación", 0, true)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range service.CheckOrder(&model.OrderStatus{OrderID: "ORD-CHK", Status: tt.status, History: tt.history}) {
+				got = append(got, v.Code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConsistencyRepair(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		history     []model.StatusRecord
+		wantStatus  string
+		wantHistory []string // estados del historial reparado; el último es el actual
+	}{
+		{
+			name:        "sin registro actual",
+			status:      "En Preparación",
+			history:     []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("En Preparación", 5, false)},
+			wantStatus:  "En Preparación",
+			wantHistory: []string{"Pendiente", "En Preparación"},
+		},
+		{
+			name:        "historial desordenado",
+			status:      "En Preparación",
+			history:     []model.StatusRecord{historyRecord("Pendiente", 0, false), historyRecord("Enviado", 10, false), historyRecord("En Preparación", 5, true)},
+			wantStatus:  "Enviado",
+			wantHistory: []string{"Pendiente", "En Preparación", "Enviado"},
+		},
+		{
+			name:        "empates conservan el orden",
+			status:      "Pendiente",
+			history:     []model.StatusRecord{historyRecord("Pendiente", 0, true), historyRecord("En Preparación", 0, true)},
+			wantStatus:  "En Preparación",
+			wantHistory: []string{"Pendiente", "En Preparación"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			orders := repository.NewMemoryOrderRepository()
+			for _, o := range []*model.OrderStatus{
+				{OrderID: "ORD-OK", Status: "Pendiente", CreatedAt: checkedAt, History: []model.StatusRecord{historyRecord("Pendiente", 0, true)}},
+				{OrderID: "ORD-BAD", Status: tt.status, CreatedAt: checkedAt, History: tt.history},
+			} {
+				if err := orders.Save(ctx, o); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := service.NewConsistencyChecker(orders).Run(ctx, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Scanned != 2 || report.Invalid != 1 || report.Fixed != 1 || report.Failed != 0 {
+				t.Fatalf("reporte inesperado: %+v", report)
+			}
+
+			ord, err := orders.FindByOrderID(ctx, "ORD-BAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var history []string
+			for _, h := range ord.History {
+				history = append(history, h.Status)
+			}
+			if ord.Status != tt.wantStatus || !slices.Equal(history, tt.wantHistory) {
+				t.Fatalf("se esperaba %q %v, se obtuvo %q %v", tt.wantStatus, tt.wantHistory, ord.Status, history)
+			}
+			if v := service.CheckOrder(ord); len(v) != 0 {
+				t.Fatalf("la orden reparada sigue inconsistente: %+v", v)
+			}
+		})
+	}
+}
+
+func TestConsistencyReportOnly(t *testing.T) {
+	ctx := context.Background()
+	orders := repository.NewMemoryOrderRepository()
+	bad := &model.OrderStatus{OrderID: "ORD-BAD", Status: "Enviado", CreatedAt: checkedAt, History: []model.StatusRecord{historyRecord("Pendiente", 0, true)}}
+	if err := orders.Save(ctx, bad); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sin fix sólo se informa: la orden no cambia
+	report, err := service.NewConsistencyChecker(orders).Run(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Invalid != 1 || report.Fixed != 0 || report.Orders[0].Fixed {
+		t.Fatalf("reporte inesperado: %+v", report)
+	}
+	ord, err := orders.FindByOrderID(ctx, "ORD-BAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.Status != "Enviado" || ord.Version != 0 {
+		t.Fatalf("el chequeo sin fix modificó la orden: %q versión %d", ord.Status, ord.Version)
+	}
+}
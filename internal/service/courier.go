@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/model"
+)
+
+// Estado en el que una orden se puede asignar a un repartidor
+const CourierAssignableStatus = "Enviado"
+
+var (
+	ErrCourierRequired     = errors.New("el repartidor es obligatorio")
+	ErrCourierAssignStatus = fmt.Errorf("sólo se pueden asignar a un repartidor órdenes en %q", CourierAssignableStatus)
+)
+
+// AssignCourier asigna (o reasigna) una orden en "Enviado" a un repartidor.
+// La asignación queda en el historial como un registro que no cambia el estado.
+func (s *OrderStatusService) AssignCourier(ctx context.Context, orderID, courierID, reason, actorID string) error {
+	courierID = strings.TrimSpace(courierID)
+	if courierID == "" {
+		return ErrCourierRequired
+	}
+
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if ord.Status != CourierAssignableStatus {
+		return ErrCourierAssignStatus
+	}
+	if ord.CourierID == courierID {
+		return nil
+	}
+
+	if reason == "" {
+		reason = "Asignada al repartidor " + courierID
+	}
+	record := model.StatusRecord{
+		Status:    ord.Status,
+		Reason:    reason,
+		UserID:    actorID,
+		Timestamp: time.Now(),
+		CourierID: courierID,
+		Current:   true,
+	}
+	return s.repo.AssignCourier(ctx, orderID, ord.Version, courierID, record)
+}
+
+// GetCourierOrders devuelve una página de las órdenes asignadas al repartidor
+func (s *OrderStatusService) GetCourierOrders(ctx context.Context, q model.OrderQuery, next string, actor Actor) (*OrderPage, error) {
+	if !actor.Has(RoleCourier) {
+		return nil, ErrForbidden
+	}
+	q.CourierID = actor.ID
+	return s.ListOrders(ctx, q, next)
+}
+
+// isAssignedCourier indica si el actor es el repartidor asignado a la orden
+func isAssignedCourier(ord *model.OrderStatus, actor Actor) bool {
+	return actor.Has(RoleCourier) && ord.CourierID != "" && ord.CourierID == actor.ID
+}
+
+// isAssignment indica si el registro del historial es una asignación de repartidor
+// (no una transición de estado)
+func isAssignment(r model.StatusRecord) bool {
+	return r.CourierID != ""
+}
@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/service"
+)
+
+var (
+	courier      = service.Actor{ID: "courier-1", Roles: []string{service.RoleCourier}}
+	otherCourier = service.Actor{ID: "courier-2", Roles: []string{service.RoleCourier}}
+)
+
+// assignedOrder deja una orden en "Enviado" asignada a courier
+func assignedOrder(t *testing.T, svc *service.OrderStatusService, orderID string) {
+	t.Helper()
+	initOrder(t, svc, orderID)
+	moveTo(t, svc, orderID,
+		dto.UpdateStatusRequest{Status: "En Preparación"},
+		dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+	if err := svc.AssignCourier(context.Background(), orderID, courier.ID, "", admin.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssignCourier(t *testing.T) {
+	tests := []struct {
+		name        string
+		notShipped  bool
+		courierID   string
+		wantErr     error
+		wantCourier string
+		wantRecs    int // registros del historial al terminar
+	}{
+		{name: "reasignar", courierID: "courier-2", wantCourier: "courier-2", wantRecs: 5},
+		{name: "mismo repartidor no agrega registro", courierID: " courier-1 ", wantCourier: "courier-1", wantRecs: 4},
+		{name: "sin repartidor", courierID: "  ", wantErr: service.ErrCourierRequired, wantCourier: "courier-1", wantRecs: 4},
+		{name: "orden sin despachar", notShipped: true, courierID: "courier-1", wantErr: service.ErrCourierAssignStatus, wantRecs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			if tt.notShipped {
+				initOrder(t, svc, "ORD-CUR")
+				moveTo(t, svc, "ORD-CUR", dto.UpdateStatusRequest{Status: "En Preparación"})
+			} else {
+				assignedOrder(t, svc, "ORD-CUR")
+			}
+
+			err := svc.AssignCourier(ctx, "ORD-CUR", tt.courierID, "", admin.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			ord, err := orders.FindByOrderID(ctx, "ORD-CUR")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ord.CourierID != tt.wantCourier || len(ord.History) != tt.wantRecs {
+				t.Fatalf("se esperaba %q con %d registros, se obtuvo %q con %d", tt.wantCourier, tt.wantRecs, ord.CourierID, len(ord.History))
+			}
+			// La asignación no cambia el estado
+			if ord.History[len(ord.History)-1].Status != ord.Status {
+				t.Fatalf("el registro actual (%q) no coincide con el estado %q", ord.History[len(ord.History)-1].Status, ord.Status)
+			}
+		})
+	}
+}
+
+func TestCourierScope(t *testing.T) {
+	read := func(ctx context.Context, svc *service.OrderStatusService, actor service.Actor) error {
+		_, err := svc.GetOrderFor(ctx, "ORD-CUR", actor)
+		return err
+	}
+	deliver := func(ctx context.Context, svc *service.OrderStatusService, actor service.Actor) error {
+		return svc.UpdateStatus(ctx, "ORD-CUR", dto.UpdateStatusRequest{Status: "Entregado"}, actor)
+	}
+	returnToSender := func(ctx context.Context, svc *service.OrderStatusService, actor service.Actor) error {
+		return svc.UpdateStatus(ctx, "ORD-CUR", dto.UpdateStatusRequest{
+			Status: "Devuelto al remitente",
+			Data:   dto.TransitionDataDTO{ReasonCode: "MAX_DELIVERY_ATTEMPTS"},
+		}, actor)
+	}
+
+	tests := []struct {
+		name    string
+		actor   service.Actor
+		do      func(ctx context.Context, svc *service.OrderStatusService, actor service.Actor) error
+		wantErr error
+	}{
+		{name: "el asignado ve la orden", actor: courier, do: read},
+		{name: "otro repartidor no la ve", actor: otherCourier, do: read, wantErr: service.ErrForbidden},
+		{name: "el asignado entrega", actor: courier, do: deliver},
+		{name: "otro repartidor no entrega", actor: otherCourier, do: deliver, wantErr: service.ErrForbidden},
+		{name: "el asignado no devuelve al remitente", actor: courier, do: returnToSender, wantErr: service.ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, _ := newService(t)
+			assignedOrder(t, svc, "ORD-CUR")
+
+			if err := tt.do(ctx, svc, tt.actor); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGetCourierOrders(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	assignedOrder(t, svc, "ORD-CUR-1")
+	assignedOrder(t, svc, "ORD-CUR-2")
+	initOrder(t, svc, "ORD-CUR-3")
+	if err := svc.AssignCourier(ctx, "ORD-CUR-2", otherCourier.ID, "", admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		actor   service.Actor
+		want    []string
+		wantErr error
+	}{
+		{name: "courier-1", actor: courier, want: []string{"ORD-CUR-1"}},
+		{name: "courier-2", actor: otherCourier, want: []string{"ORD-CUR-2"}},
+		{name: "sin rol de repartidor", actor: admin, wantErr: service.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := svc.GetCourierOrders(ctx, model.OrderQuery{Limit: 10}, "", tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, o := range page.Orders {
+				got = append(got, o.OrderID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.want, got)
+			}
+		})
+	}
+}
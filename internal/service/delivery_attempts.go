@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Estado final al que pasa la orden después de MaxDeliveryAttempts intentos fallidos
+const (
+	ReturnedToSenderStatus    = "Devuelto al remitente"
+	ReasonMaxDeliveryAttempts = "MAX_DELIVERY_ATTEMPTS"
+)
+
+var (
+	ErrAttemptStatus     = fmt.Errorf("sólo se registran intentos de entrega de órdenes en %q", ShipmentShipped)
+	ErrNextAttemptInPast = errors.New("la fecha del próximo intento tiene que ser futura")
+)
+
+// RecordDeliveryAttempt registra un intento de entrega fallido de una orden en "Enviado".
+// Lo puede registrar un admin o el repartidor asignado. Al llegar a
+// policy.MaxDeliveryAttempts, la orden pasa a "Devuelto al remitente" como actor
+// "system", siempre que el workflow lo permita desde "Enviado".
+// Devuelve el intento y el estado en que quedó la orden.
+func (s *OrderStatusService) RecordDeliveryAttempt(ctx context.Context, orderID string, req dto.DeliveryAttemptRequest, actor Actor) (*model.DeliveryAttempt, string, error) {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !actor.Has(RoleAdmin) && !isAssignedCourier(ord, actor) {
+		return nil, "", ErrForbidden
+	}
+	if ord.Status != ShipmentShipped {
+		return nil, "", ErrAttemptStatus
+	}
+
+	now := time.Now()
+	if req.NextAttemptAt != nil && !req.NextAttemptAt.After(now) {
+		return nil, "", ErrNextAttemptInPast
+	}
+
+	attempt := model.DeliveryAttempt{
+		Number:        len(ord.Attempts) + 1,
+		Reason:        req.Reason,
+		NextAttemptAt: req.NextAttemptAt,
+		UserID:        actor.ID,
+		Timestamp:     now,
+	}
+	if err := s.repo.AddDeliveryAttempt(ctx, orderID, ord.Version, attempt); err != nil {
+		return nil, "", err
+	}
+
+	if attempt.Number < s.policy.MaxDeliveryAttempts {
+		return &attempt, ord.Status, nil
+	}
+
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return nil, "", err
+	}
+	if !wf.CanTransition(RoleAdmin, ord.Status, ReturnedToSenderStatus) {
+		log.Printf("⚠ Orden %s: %d intentos fallidos, pero el workflow %s no permite pasar de %q a %q",
+			orderID, attempt.Number, wf.Name, ord.Status, ReturnedToSenderStatus)
+		return &attempt, ord.Status, nil
+	}
+
+	err = s.UpdateStatus(ctx, orderID, dto.UpdateStatusRequest{
+		Status: ReturnedToSenderStatus,
+		Reason: fmt.Sprintf("%d intentos de entrega fallidos", attempt.Number),
+		Data:   dto.TransitionDataDTO{ReasonCode: ReasonMaxDeliveryAttempts},
+	}, SystemActor)
+	if err != nil {
+		log.Printf("❌ Orden %s: no se pudo pasar a %q: %v", orderID, ReturnedToSenderStatus, err)
+		return &attempt, ord.Status, nil
+	}
+	return &attempt, ReturnedToSenderStatus, nil
+}
@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/service"
+)
+
+func TestRecordDeliveryAttempt(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		notShipped  bool
+		previous    int // intentos registrados antes de probar
+		actor       service.Actor
+		next        *time.Time
+		wantErr     error
+		wantNumber  int
+		wantStatus  string
+		wantAttempt int // intentos guardados al terminar
+	}{
+		{name: "primer intento", actor: admin, next: &future, wantNumber: 1, wantStatus: "Enviado", wantAttempt: 1},
+		{name: "el repartidor asignado", actor: courier, wantNumber: 1, wantStatus: "Enviado", wantAttempt: 1},
+		{name: "último antes del máximo", previous: 1, actor: admin, wantNumber: 2, wantStatus: "Enviado", wantAttempt: 2},
+		{name: "al llegar al máximo vuelve al remitente", previous: 2, actor: courier, wantNumber: 3, wantStatus: service.ReturnedToSenderStatus, wantAttempt: 3},
+		{name: "otro repartidor", actor: otherCourier, wantErr: service.ErrForbidden, wantStatus: "Enviado"},
+		{name: "el dueño", actor: owner, wantErr: service.ErrForbidden, wantStatus: "Enviado"},
+		{name: "próximo intento en el pasado", actor: admin, next: &past, wantErr: service.ErrNextAttemptInPast, wantStatus: "Enviado"},
+		{name: "orden sin despachar", notShipped: true, actor: admin, wantErr: service.ErrAttemptStatus, wantStatus: "En Preparación"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			if tt.notShipped {
+				initOrder(t, svc, "ORD-CUR")
+				moveTo(t, svc, "ORD-CUR", dto.UpdateStatusRequest{Status: "En Preparación"})
+			} else {
+				assignedOrder(t, svc, "ORD-CUR")
+			}
+			for i := 0; i < tt.previous; i++ {
+				if _, _, err := svc.RecordDeliveryAttempt(ctx, "ORD-CUR", dto.DeliveryAttemptRequest{Reason: "Nadie en el domicilio"}, admin); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			attempt, status, err := svc.RecordDeliveryAttempt(ctx, "ORD-CUR", dto.DeliveryAttemptRequest{Reason: "Nadie en el domicilio", NextAttemptAt: tt.next}, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			ord, ferr := orders.FindByOrderID(ctx, "ORD-CUR")
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+			if ord.Status != tt.wantStatus || len(ord.Attempts) != tt.wantAttempt {
+				t.Fatalf("se esperaba %q con %d intentos, se obtuvo %q con %d", tt.wantStatus, tt.wantAttempt, ord.Status, len(ord.Attempts))
+			}
+			if err != nil {
+				return
+			}
+			if attempt.Number != tt.wantNumber || status != tt.wantStatus || attempt.UserID != tt.actor.ID {
+				t.Fatalf("se esperaba el intento %d con la orden en %q, se obtuvo %d en %q", tt.wantNumber, tt.wantStatus, attempt.Number, status)
+			}
+
+			// La devolución al remitente la hace el sistema, con el código del motivo
+			if status == service.ReturnedToSenderStatus {
+				last := ord.History[len(ord.History)-1]
+				if last.UserID != service.SystemActorID || last.ReasonCode != service.ReasonMaxDeliveryAttempts {
+					t.Fatalf("registro inesperado: %+v", last)
+				}
+			}
+		})
+	}
+}
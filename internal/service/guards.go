@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Datos que un estado puede exigir para entrar en él (campo "requires" del workflow).
+// Los nombres coinciden con los del payload "data" de UpdateStatusRequest.
+const (
+	FieldCarrier        = "carrier"
+	FieldTrackingNumber = "trackingNumber"
+	FieldReasonCode     = "reasonCode"
+	// Se completa subiendo la constancia (ver proof.go), no desde el payload "data"
+	FieldProofOfDelivery = "proofOfDelivery"
+)
+
+var knownFields = []string{FieldCarrier, FieldTrackingNumber, FieldReasonCode, FieldProofOfDelivery}
+
+func isKnownField(f string) bool {
+	return contains(knownFields, f)
+}
+
+// MissingFieldsError se devuelve cuando la transición no trae los datos que exige el estado destino.
+type MissingFieldsError struct {
+	Status string
+	Fields []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("faltan datos para pasar a %q: %s", e.Status, strings.Join(e.Fields, ", "))
+}
+
+// missingFields devuelve los campos exigidos que vienen vacíos
+func missingFields(requires []string, data dto.TransitionDataDTO) []string {
+	values := map[string]string{
+		FieldCarrier:        data.Carrier,
+		FieldTrackingNumber: data.TrackingNumber,
+		FieldReasonCode:     data.ReasonCode,
+	}
+	if data.Proof != nil {
+		values[FieldProofOfDelivery] = data.Proof.RecipientName
+	}
+
+	var missing []string
+	for _, f := range requires {
+		if strings.TrimSpace(values[f]) == "" {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// dtoToModelTransitionData devuelve nil si no vino ningún dato, para no guardar un objeto vacío.
+// El código de motivo se guarda aparte, en StatusRecord.ReasonCode.
+func dtoToModelTransitionData(in dto.TransitionDataDTO) *model.TransitionData {
+	if in.Carrier == "" && in.TrackingNumber == "" && in.Proof == nil {
+		return nil
+	}
+	return &model.TransitionData{
+		Carrier:        in.Carrier,
+		TrackingNumber: in.TrackingNumber,
+		Proof:          in.Proof,
+	}
+}
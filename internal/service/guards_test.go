@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/service"
+)
+
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    []string // estados por los que pasa la orden antes de probar
+		to      string
+		data    dto.TransitionDataDTO
+		missing []string // nil: la transición se aplica
+	}{
+		{
+			name:    "Enviado sin datos",
+			from:    []string{"En Preparación"},
+			to:      "Enviado",
+			missing: []string{service.FieldCarrier, service.FieldTrackingNumber},
+		},
+		{
+			name:    "Enviado sin tracking",
+			from:    []string{"En Preparación"},
+			to:      "Enviado",
+			data:    dto.TransitionDataDTO{Carrier: "Andreani"},
+			missing: []string{service.FieldTrackingNumber},
+		},
+		{
+			name:    "Enviado con tracking en blanco",
+			from:    []string{"En Preparación"},
+			to:      "Enviado",
+			data:    dto.TransitionDataDTO{Carrier: "Andreani", TrackingNumber: "  "},
+			missing: []string{service.FieldTrackingNumber},
+		},
+		{
+			name: "Enviado completo",
+			from: []string{"En Preparación"},
+			to:   "Enviado",
+			data: dto.TransitionDataDTO{Carrier: "Andreani", TrackingNumber: "AR123"},
+		},
+		{
+			name:    "Rechazado sin código",
+			to:      "Rechazado",
+			missing: []string{service.FieldReasonCode},
+		},
+		{
+			name: "Rechazado con código",
+			to:   "Rechazado",
+			data: dto.TransitionDataDTO{ReasonCode: "OUT_OF_STOCK"},
+		},
+		{
+			name: "En Preparación no exige datos",
+			to:   "En Preparación",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			initOrder(t, svc, "ORD-GUARD")
+			for _, status := range tt.from {
+				if err := svc.UpdateStatus(ctx, "ORD-GUARD", dto.UpdateStatusRequest{Status: status}, admin); err != nil {
+					t.Fatalf("pasar a %q: %v", status, err)
+				}
+			}
+			before, err := orders.FindByOrderID(ctx, "ORD-GUARD")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = svc.UpdateStatus(ctx, "ORD-GUARD", dto.UpdateStatusRequest{Status: tt.to, Data: tt.data}, admin)
+			if tt.missing == nil {
+				if err != nil {
+					t.Fatalf("se esperaba que pase a %q, se obtuvo %v", tt.to, err)
+				}
+				return
+			}
+
+			// Los campos del error son los que el controller devuelve en el 422
+			var missing *service.MissingFieldsError
+			if !errors.As(err, &missing) {
+				t.Fatalf("se esperaba MissingFieldsError, se obtuvo %v", err)
+			}
+			if missing.Status != tt.to || !slices.Equal(missing.Fields, tt.missing) {
+				t.Fatalf("se esperaba %q %v, se obtuvo %q %v", tt.to, tt.missing, missing.Status, missing.Fields)
+			}
+			after, err := orders.FindByOrderID(ctx, "ORD-GUARD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Status != before.Status || after.Version != before.Version {
+				t.Fatalf("la transición rechazada modificó la orden: %q versión %d", after.Status, after.Version)
+			}
+		})
+	}
+}
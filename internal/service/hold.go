@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Estado de pausa (revisión de pago, problema con la dirección...). No forma parte
+// del grafo de ningún workflow: se puede entrar desde cualquier estado no final y
+// sólo se sale volviendo al estado en el que estaba la orden.
+const OnHoldStatus = "En Espera"
+
+var (
+	ErrNotOnHold    = errors.New("la orden no está en espera")
+	ErrResumeTarget = errors.New("una orden en espera sólo puede volver al estado en el que estaba")
+)
+
+// currentRecord devuelve el registro marcado como actual (nil si no hay)
+func currentRecord(ord *model.OrderStatus) *model.StatusRecord {
+	for i := len(ord.History) - 1; i >= 0; i-- {
+		if ord.History[i].Current {
+			return &ord.History[i]
+		}
+	}
+	return nil
+}
+
+// holdOrResume pone la orden en espera o la reanuda (admin o soporte).
+// El SLA del estado queda pausado: al reanudar, sla_since se corre lo que duró la pausa.
+func (s *OrderStatusService) holdOrResume(ctx context.Context, ord *model.OrderStatus, req dto.UpdateStatusRequest, actor Actor, dryRun bool) error {
+	if !actor.HasAny(holdRoles...) {
+		return ErrForbidden
+	}
+
+	now := time.Now()
+	record := model.StatusRecord{
+		Status:    req.Status,
+		Reason:    req.Reason,
+		UserID:    actor.ID,
+		Timestamp: now,
+		Current:   true,
+	}
+
+	slaSince := ord.SLASince
+	if slaSince.IsZero() {
+		slaSince = ord.UpdatedAt
+	}
+
+	if req.Status == OnHoldStatus {
+		if dryRun {
+			return nil
+		}
+		record.HeldFrom = ord.Status
+		// Se conserva el inicio del SLA del estado previo para poder descontar la pausa
+		return s.applyTransition(ctx, ord, record, slaSince)
+	}
+
+	// Reanudación: el registro actual es el de la pausa
+	hold := currentRecord(ord)
+	if hold == nil || req.Status != hold.HeldFrom {
+		return ErrResumeTarget
+	}
+	holdStart := hold.Timestamp
+	if dryRun {
+		return nil
+	}
+	return s.applyTransition(ctx, ord, record, slaSince.Add(now.Sub(holdStart)))
+}
+
+// ResumeOrder saca la orden de "En Espera" y la devuelve al estado en el que estaba.
+func (s *OrderStatusService) ResumeOrder(ctx context.Context, orderID, reason string, actor Actor) error {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	hold := currentRecord(ord)
+	if ord.Status != OnHoldStatus || hold == nil {
+		return ErrNotOnHold
+	}
+	return s.UpdateStatus(ctx, orderID, dto.UpdateStatusRequest{
+		Status: hold.HeldFrom,
+		Reason: reason,
+	}, actor)
+}
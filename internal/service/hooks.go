@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"order-status-service-2/internal/model"
+)
+
+// TransitionEvent es lo que reciben los hooks después de un cambio de estado exitoso.
+// Order ya refleja el estado nuevo; los hooks no deben modificarla.
+type TransitionEvent struct {
+	Order   *model.OrderStatus
+	From    string
+	To      string
+	ActorID string
+	Record  model.StatusRecord
+}
+
+// TransitionHook es una reacción a un cambio de estado (notificar, publicar, sincronizar...).
+// Un error o panic en un hook se loguea y nunca revierte ni bloquea el cambio de estado.
+type TransitionHook interface {
+	Name() string
+	OnTransition(ctx context.Context, ev TransitionEvent) error
+}
+
+type HookMode int
+
+const (
+	HookSync  HookMode = iota // se ejecuta antes de que UpdateStatus retorne
+	HookAsync                 // se ejecuta en una goroutine aparte
+)
+
+const defaultHookTimeout = 5 * time.Second
+
+type HookOptions struct {
+	Mode    HookMode
+	Timeout time.Duration // 0 = defaultHookTimeout
+}
+
+type registeredHook struct {
+	hook TransitionHook
+	opts HookOptions
+}
+
+// RegisterHook agrega un hook. Debe llamarse al iniciar el servicio (main.go), antes de r.Run.
+func (s *OrderStatusService) RegisterHook(h TransitionHook, opts HookOptions) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHookTimeout
+	}
+	s.hooks = append(s.hooks, registeredHook{hook: h, opts: opts})
+}
+
+// runHooks ejecuta los hooks registrados. Los async no dependen de la
+// cancelación del request, sólo de su propio timeout.
+func (s *OrderStatusService) runHooks(ctx context.Context, ev TransitionEvent) {
+	for _, rh := range s.hooks {
+		if rh.opts.Mode == HookAsync {
+			go runHook(context.WithoutCancel(ctx), rh, ev)
+			continue
+		}
+		runHook(ctx, rh, ev)
+	}
+}
+
+// runHook espera al hook hasta su timeout. El hook corre en su propia goroutine:
+// si no respeta la cancelación del contexto, se deja de esperarlo (sigue en
+// segundo plano) y UpdateStatus no queda bloqueado.
+func runHook(ctx context.Context, rh registeredHook, ev TransitionEvent) {
+	ctx, cancel := context.WithTimeout(ctx, rh.opts.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- rh.hook.OnTransition(ctx, ev)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("❌ Hook %s: error en orden %s (%s → %s): %v", rh.hook.Name(), ev.Order.OrderID, ev.From, ev.To, err)
+		}
+	case <-ctx.Done():
+		log.Printf("❌ Hook %s: sin respuesta en orden %s (%s → %s) tras %s: %v", rh.hook.Name(), ev.Order.OrderID, ev.From, ev.To, rh.opts.Timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Estados de cada línea (artículo) de la orden
+const (
+	ItemPending     = "Pendiente"
+	ItemBackordered = "Sin Stock"
+	ItemPacked      = "Empaquetado"
+	ItemShipped     = "Enviado"
+	ItemDelivered   = "Entregado"
+	ItemCancelled   = "Cancelado"
+)
+
+// Transiciones permitidas para las líneas. Entregado y Cancelado son finales.
+var itemTransitions = map[string][]string{
+	ItemPending:     {ItemBackordered, ItemPacked, ItemCancelled},
+	ItemBackordered: {ItemPacked, ItemCancelled},
+	ItemPacked:      {ItemShipped, ItemCancelled},
+	ItemShipped:     {ItemDelivered},
+}
+
+func isItemStatus(s string) bool {
+	switch s {
+	case ItemPending, ItemBackordered, ItemPacked, ItemShipped, ItemDelivered, ItemCancelled:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrItemNotFound          = errors.New("la orden no tiene ese artículo")
+	ErrInvalidItemTransition = errors.New("transición de estado inválida para el artículo")
+)
+
+// ItemsNotReadyError se devuelve cuando el estado destino de la orden exige que
+// las líneas estén en ciertos estados (itemsRequire) y alguna no lo está.
+type ItemsNotReadyError struct {
+	Status   string
+	Articles []string
+}
+
+func (e *ItemsNotReadyError) Error() string {
+	return fmt.Sprintf("hay artículos que no permiten pasar a %q: %s", e.Status, strings.Join(e.Articles, ", "))
+}
+
+// newLineItems arma las líneas iniciales; si un artículo viene repetido se suman las cantidades.
+func newLineItems(articles []dto.ArticleDTO, userID string) []model.LineItem {
+	var items []model.LineItem
+	index := map[string]int{}
+	now := time.Now()
+
+	for _, a := range articles {
+		if a.ArticleID == "" {
+			continue
+		}
+		if i, ok := index[a.ArticleID]; ok {
+			items[i].Quantity += a.Quantity
+			continue
+		}
+		index[a.ArticleID] = len(items)
+		items = append(items, model.LineItem{
+			ArticleID: a.ArticleID,
+			Quantity:  a.Quantity,
+			Status:    ItemPending,
+			History: []model.StatusRecord{
+				{
+					Status:    ItemPending,
+					Reason:    "Orden inicializada",
+					UserID:    userID,
+					Timestamp: now,
+				},
+			},
+		})
+	}
+	return items
+}
+
+// pendingItems devuelve los artículos que no están en alguno de los estados exigidos.
+// Las líneas canceladas no cuentan.
+func pendingItems(items []model.LineItem, required []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+
+	var pending []string
+	for _, it := range items {
+		if it.Status == ItemCancelled || contains(required, it.Status) {
+			continue
+		}
+		pending = append(pending, it.ArticleID)
+	}
+	return pending
+}
+
+// UpdateItemStatus cambia el estado de una línea de la orden (sólo admin).
+func (s *OrderStatusService) UpdateItemStatus(ctx context.Context, orderID, articleID string, req dto.UpdateItemStatusRequest, actorID string) error {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return err
+	}
+	if wf.IsFinal(ord.Status) {
+		return ErrFinalState
+	}
+
+	var item *model.LineItem
+	for i := range ord.Items {
+		if ord.Items[i].ArticleID == articleID {
+			item = &ord.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		return ErrItemNotFound
+	}
+
+	if item.Status == req.Status {
+		return nil
+	}
+	if !isItemStatus(req.Status) || !contains(itemTransitions[item.Status], req.Status) {
+		return ErrInvalidItemTransition
+	}
+
+	record := model.StatusRecord{
+		Status:    req.Status,
+		Reason:    req.Reason,
+		UserID:    actorID,
+		Timestamp: time.Now(),
+	}
+	return s.repo.UpdateItemStatus(ctx, orderID, articleID, item.Status, req.Status, record)
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/service"
+)
+
+// initOrderWithItems inicializa una orden de envío a domicilio con los artículos indicados
+func initOrderWithItems(t *testing.T, svc *service.OrderStatusService, orderID string, articles ...dto.ArticleDTO) *model.OrderStatus {
+	t.Helper()
+	o, err := svc.InitOrderStatus(context.Background(), orderID, owner.ID, "", dto.ShippingDTO{}, articles, false)
+	if err != nil {
+		t.Fatalf("InitOrderStatus: %v", err)
+	}
+	return o
+}
+
+// setItems pasa cada artículo por los estados indicados
+func setItems(t *testing.T, svc *service.OrderStatusService, orderID string, steps map[string][]string) {
+	t.Helper()
+	for articleID, statuses := range steps {
+		for _, status := range statuses {
+			if err := svc.UpdateItemStatus(context.Background(), orderID, articleID, dto.UpdateItemStatusRequest{Status: status}, admin.ID); err != nil {
+				t.Fatalf("pasar %s a %q: %v", articleID, status, err)
+			}
+		}
+	}
+}
+
+func TestUpdateItemStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		article string
+		from    []string // estados por los que pasa el artículo antes de probar
+		to      string
+		wantErr error
+	}{
+		{name: "Pendiente → Empaquetado", article: "A-1", to: service.ItemPacked},
+		{name: "Pendiente → Sin Stock", article: "A-1", to: service.ItemBackordered},
+		{name: "Sin Stock → Empaquetado", article: "A-1", from: []string{service.ItemBackordered}, to: service.ItemPacked},
+		{name: "Empaquetado → Enviado", article: "A-1", from: []string{service.ItemPacked}, to: service.ItemShipped},
+		{name: "mismo estado no hace nada", article: "A-1", from: []string{service.ItemPacked}, to: service.ItemPacked},
+		{name: "Pendiente → Enviado", article: "A-1", to: service.ItemShipped, wantErr: service.ErrInvalidItemTransition},
+		{name: "Cancelado es final", article: "A-1", from: []string{service.ItemCancelled}, to: service.ItemPacked, wantErr: service.ErrInvalidItemTransition},
+		{name: "Enviado no se cancela", article: "A-1", from: []string{service.ItemPacked, service.ItemShipped}, to: service.ItemCancelled, wantErr: service.ErrInvalidItemTransition},
+		{name: "estado desconocido", article: "A-1", to: "Perdido", wantErr: service.ErrInvalidItemTransition},
+		{name: "artículo inexistente", article: "Z-9", to: service.ItemPacked, wantErr: service.ErrItemNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			initOrderWithItems(t, svc, "ORD-ITEM", dto.ArticleDTO{ArticleID: "A-1", Quantity: 1})
+			setItems(t, svc, "ORD-ITEM", map[string][]string{tt.article: tt.from})
+
+			err := svc.UpdateItemStatus(ctx, "ORD-ITEM", tt.article, dto.UpdateItemStatusRequest{Status: tt.to}, admin.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			ord, err := orders.FindByOrderID(ctx, "ORD-ITEM")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Registro inicial + uno por cada cambio (volver al mismo estado no agrega)
+			records := 1 + len(tt.from)
+			if !slices.Contains(tt.from, tt.to) {
+				records++
+			}
+			item := ord.Items[0]
+			if item.Status != tt.to || len(item.History) != records {
+				t.Fatalf("se esperaba %q con %d registros, se obtuvo %q con %d", tt.to, records, item.Status, len(item.History))
+			}
+		})
+	}
+}
+
+func TestItemsRequire(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   map[string][]string
+		pending []string // nil: la orden pasa a Enviado
+	}{
+		{
+			name:    "ningún artículo listo",
+			pending: []string{"A-1", "A-2"},
+		},
+		{
+			name:    "uno sin stock",
+			items:   map[string][]string{"A-1": {service.ItemPacked}, "A-2": {service.ItemBackordered}},
+			pending: []string{"A-2"},
+		},
+		{
+			name:  "empaquetados y enviados",
+			items: map[string][]string{"A-1": {service.ItemPacked}, "A-2": {service.ItemPacked, service.ItemShipped}},
+		},
+		{
+			name:  "los cancelados no cuentan",
+			items: map[string][]string{"A-1": {service.ItemPacked}, "A-2": {service.ItemCancelled}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, _ := newService(t)
+			initOrderWithItems(t, svc, "ORD-ITEM",
+				dto.ArticleDTO{ArticleID: "A-1", Quantity: 1},
+				dto.ArticleDTO{ArticleID: "A-2", Quantity: 2})
+			setItems(t, svc, "ORD-ITEM", tt.items)
+			moveTo(t, svc, "ORD-ITEM", dto.UpdateStatusRequest{Status: "En Preparación"})
+
+			err := svc.UpdateStatus(ctx, "ORD-ITEM", dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData}, admin)
+			if tt.pending == nil {
+				if err != nil {
+					t.Fatalf("se esperaba que pase a Enviado, se obtuvo %v", err)
+				}
+				return
+			}
+			var notReady *service.ItemsNotReadyError
+			if !errors.As(err, &notReady) {
+				t.Fatalf("se esperaba ItemsNotReadyError, se obtuvo %v", err)
+			}
+			if !slices.Equal(notReady.Articles, tt.pending) {
+				t.Fatalf("se esperaban pendientes %v, se obtuvo %v", tt.pending, notReady.Articles)
+			}
+		})
+	}
+}
+
+func TestLineItemsMergeDuplicates(t *testing.T) {
+	svc, _ := newService(t)
+	ord := initOrderWithItems(t, svc, "ORD-ITEM",
+		dto.ArticleDTO{ArticleID: "A-1", Quantity: 1},
+		dto.ArticleDTO{ArticleID: "", Quantity: 5},
+		dto.ArticleDTO{ArticleID: "A-1", Quantity: 2})
+
+	if len(ord.Items) != 1 || ord.Items[0].Quantity != 3 || ord.Items[0].Status != service.ItemPending {
+		t.Fatalf("se esperaba una línea A-1 x3 Pendiente, se obtuvo %+v", ord.Items)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"order-status-service-2/internal/model"
+)
+
+// Tamaño de página de los listados de órdenes
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+var (
+	ErrInvalidSort   = errors.New("orden inválido: sólo created_at o updated_at")
+	ErrInvalidCursor = errors.New("token de paginación inválido")
+)
+
+// OrderPage es una página de un listado. Next es el token para pedir la
+// siguiente (vacío si no hay más).
+type OrderPage struct {
+	Orders []*model.OrderStatus `json:"orders"`
+	Next   string               `json:"next,omitempty"`
+}
+
+// pageToken es el contenido del token "next". Guarda también el orden pedido,
+// para rechazar un token usado con otro orden.
+type pageToken struct {
+	SortBy  string    `json:"s"`
+	Desc    bool      `json:"d"`
+	Value   time.Time `json:"v"`
+	OrderID string    `json:"id"`
+}
+
+// ListOrders devuelve una página de órdenes que cumplen los filtros de q.
+// next es el token devuelto en la página anterior ("" para la primera).
+// Por defecto se ordena por created_at, de la más nueva a la más vieja.
+func (s *OrderStatusService) ListOrders(ctx context.Context, q model.OrderQuery, next string) (*OrderPage, error) {
+	if q.SortBy == "" {
+		q.SortBy = model.SortCreatedAt
+	}
+	if q.SortBy != model.SortCreatedAt && q.SortBy != model.SortUpdatedAt {
+		return nil, ErrInvalidSort
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultPageLimit
+	}
+	q.Limit = min(q.Limit, maxPageLimit)
+
+	if next != "" {
+		after, err := decodePageToken(next, q)
+		if err != nil {
+			return nil, err
+		}
+		q.After = after
+	}
+
+	// Se pide uno de más para saber si hay otra página
+	limit := q.Limit
+	q.Limit++
+	orders, err := s.repo.FindPage(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.Next = encodePageToken(q, page.Orders[limit-1])
+	}
+	if page.Orders == nil {
+		page.Orders = []*model.OrderStatus{}
+	}
+	return page, nil
+}
+
+func encodePageToken(q model.OrderQuery, last *model.OrderStatus) string {
+	v := last.CreatedAt
+	if q.SortBy == model.SortUpdatedAt {
+		v = last.UpdatedAt
+	}
+	b, _ := json.Marshal(pageToken{
+		SortBy:  q.SortBy,
+		Desc:    q.Desc,
+		Value:   v,
+		OrderID: last.OrderID,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePageToken(next string, q model.OrderQuery) (*model.OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(next)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var t pageToken
+	if err := json.Unmarshal(b, &t); err != nil || t.OrderID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if t.SortBy != q.SortBy || t.Desc != q.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &model.OrderCursor{Value: t.Value, OrderID: t.OrderID}, nil
+}
@@ -0,0 +1,127 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/service"
+)
+
+// pagedOrders crea P-1..P-5 (en ese orden) y después modifica P-2
+func pagedOrders(t *testing.T, svc *service.OrderStatusService) {
+	t.Helper()
+	for _, id := range []string{"P-1", "P-2", "P-3", "P-4", "P-5"} {
+		initOrder(t, svc, id)
+		time.Sleep(2 * time.Millisecond)
+	}
+	moveTo(t, svc, "P-2", dto.UpdateStatusRequest{Status: "En Preparación"})
+}
+
+// allPages recorre el listado siguiendo los tokens y devuelve los ids de cada página
+func allPages(t *testing.T, svc *service.OrderStatusService, q model.OrderQuery) [][]string {
+	t.Helper()
+	var pages [][]string
+	next := ""
+	for {
+		page, err := svc.ListOrders(context.Background(), q, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, o := range page.Orders {
+			ids = append(ids, o.OrderID)
+		}
+		pages = append(pages, ids)
+		if page.Next == "" {
+			return pages
+		}
+		next = page.Next
+	}
+}
+
+func TestListOrdersPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		q     model.OrderQuery
+		pages [][]string
+	}{
+		{
+			name:  "por creación",
+			q:     model.OrderQuery{Limit: 2},
+			pages: [][]string{{"P-1", "P-2"}, {"P-3", "P-4"}, {"P-5"}},
+		},
+		{
+			name:  "por creación descendente",
+			q:     model.OrderQuery{Desc: true, Limit: 2},
+			pages: [][]string{{"P-5", "P-4"}, {"P-3", "P-2"}, {"P-1"}},
+		},
+		{
+			name:  "por actualización",
+			q:     model.OrderQuery{SortBy: model.SortUpdatedAt, Limit: 2},
+			pages: [][]string{{"P-1", "P-3"}, {"P-4", "P-5"}, {"P-2"}},
+		},
+		{
+			name:  "página justa no deja token",
+			q:     model.OrderQuery{Statuses: []string{"Pendiente"}, Limit: 4},
+			pages: [][]string{{"P-1", "P-3", "P-4", "P-5"}},
+		},
+		{
+			name:  "sin resultados",
+			q:     model.OrderQuery{Statuses: []string{"Enviado"}},
+			pages: [][]string{nil},
+		},
+	}
+
+	svc, _ := newService(t)
+	pagedOrders(t, svc)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allPages(t, svc, tt.q)
+			if !slices.EqualFunc(got, tt.pages, slices.Equal) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.pages, got)
+			}
+		})
+	}
+}
+
+func TestListOrdersCursor(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	pagedOrders(t, svc)
+	first, err := svc.ListOrders(ctx, model.OrderQuery{Limit: 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Orders == nil || first.Next == "" {
+		t.Fatalf("se esperaba una primera página con token, se obtuvo %+v", first)
+	}
+
+	tests := []struct {
+		name    string
+		q       model.OrderQuery
+		next    string
+		wantErr error
+	}{
+		{name: "mismo orden", q: model.OrderQuery{Limit: 2}, next: first.Next},
+		{name: "token con otro sentido", q: model.OrderQuery{Desc: true, Limit: 2}, next: first.Next, wantErr: service.ErrInvalidCursor},
+		{name: "token con otro campo", q: model.OrderQuery{SortBy: model.SortUpdatedAt, Limit: 2}, next: first.Next, wantErr: service.ErrInvalidCursor},
+		{name: "token roto", q: model.OrderQuery{Limit: 2}, next: "no-es-un-token", wantErr: service.ErrInvalidCursor},
+		{name: "token sin orden", q: model.OrderQuery{Limit: 2}, next: "e30", wantErr: service.ErrInvalidCursor}, // {}
+		{name: "orden inválido", q: model.OrderQuery{SortBy: "status"}, wantErr: service.ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ListOrders(ctx, tt.q, tt.next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Archivos de una constancia de entrega
+const (
+	ProofSignature = "signature" // obligatorio
+	ProofPhoto     = "photo"
+)
+
+// Tipos aceptados; se detectan por el contenido, no por lo que declara el cliente
+var allowedProofTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+var (
+	ErrProofRecipientRequired = errors.New("el nombre y el documento de quien recibe son obligatorios")
+	ErrProofSignatureRequired = errors.New("la firma es obligatoria")
+	ErrProofFileTooLarge      = errors.New("el archivo supera el tamaño máximo permitido")
+	ErrProofFileType          = errors.New("tipo de archivo no permitido")
+	ErrProofFileNotFound      = errors.New("la orden no tiene ese archivo de constancia")
+)
+
+// FileStore guarda los archivos de las constancias (lo implementa repository con GridFS)
+type FileStore interface {
+	Upload(ctx context.Context, filename, contentType string, content io.Reader) (string, error)
+	Open(ctx context.Context, fileID string) (io.ReadCloser, error)
+	Delete(ctx context.Context, fileID string) error
+}
+
+// ProofUpload es un archivo de la constancia tal como llega en el multipart
+type ProofUpload struct {
+	Kind     string // signature, photo
+	Filename string
+	Size     int64
+	Content  io.Reader
+}
+
+// DeliverWithProof pasa la orden a "Entregado" guardando la constancia de entrega
+// en el registro del historial. Se valida la transición antes de subir los archivos,
+// y si al final no se puede aplicar, los archivos subidos se borran.
+func (s *OrderStatusService) DeliverWithProof(ctx context.Context, orderID, recipientName, recipientDocument, reason string, uploads []ProofUpload, actor Actor) (*model.ProofOfDelivery, error) {
+	proof := &model.ProofOfDelivery{
+		RecipientName:     strings.TrimSpace(recipientName),
+		RecipientDocument: strings.TrimSpace(recipientDocument),
+	}
+	if proof.RecipientName == "" || proof.RecipientDocument == "" {
+		return nil, ErrProofRecipientRequired
+	}
+
+	// Tipo y tamaño de cada archivo, antes de escribir nada
+	hasSignature := false
+	readers := make([]io.Reader, len(uploads))
+	for i, up := range uploads {
+		if up.Kind == ProofSignature {
+			hasSignature = true
+		}
+		if up.Size > s.policy.ProofMaxFileSize {
+			return nil, fmt.Errorf("%w: %s (máximo %d bytes)", ErrProofFileTooLarge, up.Filename, s.policy.ProofMaxFileSize)
+		}
+		contentType, r, err := sniffContentType(up.Content)
+		if err != nil {
+			return nil, err
+		}
+		if !contains(allowedProofTypes, contentType) {
+			return nil, fmt.Errorf("%w: %s es %s", ErrProofFileType, up.Filename, contentType)
+		}
+		readers[i] = r
+		proof.Files = append(proof.Files, model.DeliveryFile{
+			Kind:        up.Kind,
+			Filename:    up.Filename,
+			ContentType: contentType,
+			Size:        up.Size,
+		})
+	}
+	if !hasSignature {
+		return nil, ErrProofSignatureRequired
+	}
+
+	req := dto.UpdateStatusRequest{
+		Status: ShipmentDelivered,
+		Reason: reason,
+		Data:   dto.TransitionDataDTO{Proof: proof},
+	}
+	if err := s.updateStatus(ctx, orderID, req, actor, true); err != nil {
+		return nil, err
+	}
+
+	for i := range proof.Files {
+		f := &proof.Files[i]
+		id, err := s.files.Upload(ctx, f.Filename, f.ContentType, io.LimitReader(readers[i], s.policy.ProofMaxFileSize))
+		if err != nil {
+			s.deleteProofFiles(ctx, proof.Files[:i])
+			return nil, err
+		}
+		f.FileID = id
+	}
+
+	if err := s.updateStatus(ctx, orderID, req, actor, false); err != nil {
+		s.deleteProofFiles(ctx, proof.Files)
+		return nil, err
+	}
+	return proof, nil
+}
+
+// OpenProofFile abre un archivo de la constancia de entrega de la orden.
+// Sólo pueden descargarlo el dueño de la orden y los admins.
+func (s *OrderStatusService) OpenProofFile(ctx context.Context, orderID, fileID string, actor Actor) (*model.DeliveryFile, io.ReadCloser, error) {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ord.UserID != actor.ID && !actor.Has(RoleAdmin) {
+		return nil, nil, ErrForbidden
+	}
+
+	file := findProofFile(ord.History, fileID)
+	if file == nil {
+		return nil, nil, ErrProofFileNotFound
+	}
+	content, err := s.files.Open(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, content, nil
+}
+
+// findProofFile busca el archivo en las constancias del historial (puede haber
+// más de una si la entrega se revirtió y se volvió a registrar)
+func findProofFile(history []model.StatusRecord, fileID string) *model.DeliveryFile {
+	for _, h := range history {
+		if h.Data == nil || h.Data.Proof == nil {
+			continue
+		}
+		for i := range h.Data.Proof.Files {
+			if h.Data.Proof.Files[i].FileID == fileID {
+				return &h.Data.Proof.Files[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (s *OrderStatusService) deleteProofFiles(ctx context.Context, files []model.DeliveryFile) {
+	for _, f := range files {
+		if err := s.files.Delete(ctx, f.FileID); err != nil {
+			log.Printf("❌ No se pudo borrar el archivo de constancia %s: %v", f.FileID, err)
+		}
+	}
+}
+
+// sniffContentType detecta el tipo del archivo por sus primeros bytes y devuelve
+// un reader que sigue incluyéndolos.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", nil, err
+	}
+	return http.DetectContentType(head), br, nil
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+var errUploadFailed = errors.New("falló la subida")
+
+// proofFiles es el almacenamiento en memoria, con una subida que puede fallar
+// (failAt, contando desde 1) y algo que pasa en la primera subida (onUpload).
+type proofFiles struct {
+	*repository.MemoryFileStore
+	failAt   int
+	onUpload func()
+	uploaded []string
+}
+
+func (f *proofFiles) Upload(ctx context.Context, filename, contentType string, content io.Reader) (string, error) {
+	if len(f.uploaded) == 0 && f.onUpload != nil {
+		f.onUpload()
+	}
+	if len(f.uploaded)+1 == f.failAt {
+		return "", errUploadFailed
+	}
+	id, err := f.MemoryFileStore.Upload(ctx, filename, contentType, content)
+	if err == nil {
+		f.uploaded = append(f.uploaded, id)
+	}
+	return id, err
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func proofUpload(kind string, content []byte) service.ProofUpload {
+	return service.ProofUpload{Kind: kind, Filename: kind + ".png", Size: int64(len(content)), Content: bytes.NewReader(content)}
+}
+
+func TestDeliverWithProof(t *testing.T) {
+	signature := proofUpload(service.ProofSignature, pngHeader)
+	photo := proofUpload(service.ProofPhoto, pngHeader)
+
+	tests := []struct {
+		name       string
+		notShipped bool // la orden queda en "En Preparación"
+		recipient  string
+		uploads    []service.ProofUpload
+		failAt     int
+		moveAway   bool // otro pedido devuelve la orden al remitente mientras se suben los archivos
+		wantErr    error
+		wantStored int // archivos que quedan guardados
+	}{
+		{name: "firma y foto", recipient: "Ana Pérez", uploads: []service.ProofUpload{signature, photo}, wantStored: 2},
+		{name: "sin quien recibe", recipient: " ", uploads: []service.ProofUpload{signature}, wantErr: service.ErrProofRecipientRequired},
+		{name: "sin firma", recipient: "Ana Pérez", uploads: []service.ProofUpload{photo}, wantErr: service.ErrProofSignatureRequired},
+		{
+			name:      "archivo muy grande",
+			recipient: "Ana Pérez",
+			uploads:   []service.ProofUpload{{Kind: service.ProofSignature, Filename: "firma.png", Size: 2 << 20, Content: bytes.NewReader(pngHeader)}},
+			wantErr:   service.ErrProofFileTooLarge,
+		},
+		{
+			name:      "tipo no permitido",
+			recipient: "Ana Pérez",
+			uploads:   []service.ProofUpload{proofUpload(service.ProofSignature, []byte("no es una imagen"))},
+			wantErr:   service.ErrProofFileType,
+		},
+		{name: "transición inválida", notShipped: true, recipient: "Ana Pérez", uploads: []service.ProofUpload{signature}, wantErr: service.ErrInvalidTransition},
+		{name: "falla la segunda subida", recipient: "Ana Pérez", uploads: []service.ProofUpload{signature, photo}, failAt: 2, wantErr: errUploadFailed},
+		{name: "la orden cambia durante la subida", recipient: "Ana Pérez", uploads: []service.ProofUpload{signature, photo}, moveAway: true, wantErr: service.ErrFinalState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			files := &proofFiles{MemoryFileStore: repository.NewMemoryFileStore(), failAt: tt.failAt}
+			svc, orders := newServiceWithFiles(t, files)
+			initOrder(t, svc, "ORD-POD")
+			moveTo(t, svc, "ORD-POD", dto.UpdateStatusRequest{Status: "En Preparación"})
+			if !tt.notShipped {
+				moveTo(t, svc, "ORD-POD", dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+			}
+			if tt.moveAway {
+				files.onUpload = func() {
+					moveTo(t, svc, "ORD-POD", dto.UpdateStatusRequest{Status: "Devuelto al remitente", Data: dto.TransitionDataDTO{ReasonCode: "MAX_DELIVERY_ATTEMPTS"}})
+				}
+			}
+			// Los casos comparten los readers: se vuelven a leer desde el principio
+			for _, up := range tt.uploads {
+				if _, err := up.Content.(io.Seeker).Seek(0, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			proof, err := svc.DeliverWithProof(ctx, "ORD-POD", tt.recipient, "30111222", "", tt.uploads, admin)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+
+			// Los archivos de una entrega que no se aplicó se borran
+			stored := 0
+			for _, id := range files.uploaded {
+				if _, err := files.Open(ctx, id); err == nil {
+					stored++
+				} else if !errors.Is(err, repository.ErrFileNotFound) {
+					t.Fatal(err)
+				}
+			}
+			if stored != tt.wantStored {
+				t.Fatalf("se esperaban %d archivos guardados, quedaron %d", tt.wantStored, stored)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			ord, err := orders.FindByOrderID(ctx, "ORD-POD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := ord.History[len(ord.History)-1]
+			if ord.Status != "Entregado" || last.Data == nil || last.Data.Proof == nil || len(last.Data.Proof.Files) != len(proof.Files) {
+				t.Fatalf("se esperaba Entregado con la constancia en el historial, se obtuvo %q %+v", ord.Status, last.Data)
+			}
+			for _, f := range proof.Files {
+				if f.ContentType != "image/png" || f.FileID == "" {
+					t.Fatalf("archivo inesperado: %+v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenProofFile(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	initOrder(t, svc, "ORD-POD")
+	moveTo(t, svc, "ORD-POD",
+		dto.UpdateStatusRequest{Status: "En Preparación"},
+		dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+	proof, err := svc.DeliverWithProof(ctx, "ORD-POD", "Ana Pérez", "30111222", "", []service.ProofUpload{proofUpload(service.ProofSignature, pngHeader)}, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileID := proof.Files[0].FileID
+
+	tests := []struct {
+		name    string
+		actor   service.Actor
+		fileID  string
+		wantErr error
+	}{
+		{name: "dueño", actor: owner, fileID: fileID},
+		{name: "admin", actor: admin, fileID: fileID},
+		{name: "otro usuario", actor: service.Actor{ID: "user-2"}, fileID: fileID, wantErr: service.ErrForbidden},
+		{name: "archivo de otra orden", actor: owner, fileID: strings.Repeat("0", 24), wantErr: service.ErrProofFileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, content, err := svc.OpenProofFile(ctx, "ORD-POD", tt.fileID, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer content.Close()
+			data, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Kind != service.ProofSignature || !bytes.Equal(data, pngHeader) {
+				t.Fatalf("se esperaba la firma, se obtuvo %s con %d bytes", file.Kind, len(data))
+			}
+		})
+	}
+}
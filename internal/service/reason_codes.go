@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"order-status-service-2/internal/model"
+)
+
+var ErrInvalidReasonCode = errors.New("código de motivo inválido para ese estado")
+
+// Idioma de las etiquetas cuando no se pide otro (o no existe la traducción)
+const defaultLang = "es"
+
+// ReasonCodeCatalog es el catálogo de motivos cargado desde workflow.yaml
+type ReasonCodeCatalog struct {
+	codes map[string]model.ReasonCode
+}
+
+func NewReasonCodeCatalog(codes []model.ReasonCode) *ReasonCodeCatalog {
+	c := &ReasonCodeCatalog{codes: make(map[string]model.ReasonCode, len(codes))}
+	for _, rc := range codes {
+		c.codes[rc.Code] = rc
+	}
+	return c
+}
+
+// Check verifica que el código exista y se pueda usar para pasar al estado indicado
+func (c *ReasonCodeCatalog) Check(code, status string) error {
+	rc, ok := c.codes[code]
+	if !ok || !contains(rc.AppliesTo, status) {
+		return fmt.Errorf("%w: %q en %q", ErrInvalidReasonCode, code, status)
+	}
+	return nil
+}
+
+// List devuelve los motivos ordenados por código; si status no está vacío,
+// sólo los que se pueden usar para ese estado.
+func (c *ReasonCodeCatalog) List(status string) []model.ReasonCode {
+	out := []model.ReasonCode{}
+	for _, rc := range c.codes {
+		if status == "" || contains(rc.AppliesTo, status) {
+			out = append(out, rc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// Label devuelve la etiqueta en el idioma pedido, o en español si no está traducida
+func (c *ReasonCodeCatalog) Label(code, lang string) string {
+	rc, ok := c.codes[code]
+	if !ok {
+		return code
+	}
+	if l, ok := rc.Labels[strings.ToLower(lang)]; ok {
+		return l
+	}
+	return rc.Labels[defaultLang]
+}
+
+// validateReasonCodes revisa el catálogo de motivos contra los workflows:
+// códigos únicos con etiqueta en español, estados existentes, y que todo estado
+// que exige reasonCode (o regla de SLA que lo usa) tenga al menos un código válido.
+func (f *WorkflowFile) validateReasonCodes() error {
+	var errs []error
+
+	states := map[string]bool{}
+	for _, d := range f.Workflows {
+		for _, st := range d.States {
+			states[st.Name] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, rc := range f.ReasonCodes {
+		if strings.TrimSpace(rc.Code) == "" {
+			errs = append(errs, errors.New("hay un código de motivo vacío"))
+			continue
+		}
+		if seen[rc.Code] {
+			errs = append(errs, fmt.Errorf("código de motivo %q duplicado", rc.Code))
+		}
+		seen[rc.Code] = true
+
+		if rc.Labels[defaultLang] == "" {
+			errs = append(errs, fmt.Errorf("código de motivo %q sin etiqueta %q", rc.Code, defaultLang))
+		}
+		if len(rc.AppliesTo) == 0 {
+			errs = append(errs, fmt.Errorf("código de motivo %q no aplica a ningún estado", rc.Code))
+		}
+		for _, st := range rc.AppliesTo {
+			if !states[st] {
+				errs = append(errs, fmt.Errorf("código de motivo %q: estado inexistente %q", rc.Code, st))
+			}
+		}
+	}
+
+	catalog := NewReasonCodeCatalog(f.ReasonCodes)
+	for _, d := range f.Workflows {
+		for _, st := range d.States {
+			if contains(st.Requires, FieldReasonCode) && len(catalog.List(st.Name)) == 0 {
+				errs = append(errs, fmt.Errorf("workflow %q: %q exige reasonCode pero ningún código aplica", d.Name, st.Name))
+			}
+			t := st.Timeout
+			if t != nil && t.ReasonCode != "" && t.Action == TimeoutTransition {
+				if err := catalog.Check(t.ReasonCode, t.To); err != nil {
+					errs = append(errs, fmt.Errorf("workflow %q: timeout de %q: %w", d.Name, st.Name, err))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
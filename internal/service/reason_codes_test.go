@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/service"
+)
+
+var testReasonCodes = []model.ReasonCode{
+	{Code: "OUT_OF_STOCK", AppliesTo: []string{"Rechazado"}, Labels: map[string]string{"es": "Sin stock", "en": "Out of stock"}},
+	{Code: "OTHER", AppliesTo: []string{"Cancelado", "Rechazado"}, Labels: map[string]string{"es": "Otro motivo"}},
+}
+
+func TestReasonCodeCheck(t *testing.T) {
+	catalog := service.NewReasonCodeCatalog(testReasonCodes)
+	tests := []struct {
+		code, status string
+		ok           bool
+	}{
+		{"OUT_OF_STOCK", "Rechazado", true},
+		{"OUT_OF_STOCK", "Cancelado", false},
+		{"OTHER", "Cancelado", true},
+		{"OTHER", "Rechazado", true},
+		{"NO_EXISTE", "Rechazado", false},
+		{"", "Rechazado", false},
+	}
+	for _, tt := range tests {
+		err := catalog.Check(tt.code, tt.status)
+		if tt.ok && err != nil {
+			t.Errorf("Check(%q, %q): %v", tt.code, tt.status, err)
+		}
+		if !tt.ok && !errors.Is(err, service.ErrInvalidReasonCode) {
+			t.Errorf("Check(%q, %q): se esperaba ErrInvalidReasonCode, se obtuvo %v", tt.code, tt.status, err)
+		}
+	}
+}
+
+func TestReasonCodeLabel(t *testing.T) {
+	catalog := service.NewReasonCodeCatalog(testReasonCodes)
+	tests := []struct {
+		code, lang, want string
+	}{
+		{"OUT_OF_STOCK", "en", "Out of stock"},
+		{"OUT_OF_STOCK", "EN", "Out of stock"},
+		{"OUT_OF_STOCK", "es", "Sin stock"},
+		{"OTHER", "en", "Otro motivo"}, // sin traducción: español
+		{"NO_EXISTE", "es", "NO_EXISTE"},
+	}
+	for _, tt := range tests {
+		if got := catalog.Label(tt.code, tt.lang); got != tt.want {
+			t.Errorf("Label(%q, %q) = %q, se esperaba %q", tt.code, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestReasonCodeOnTransition(t *testing.T) {
+	tests := []struct {
+		name   string
+		actor  service.Actor
+		status string
+		code   string
+		ok     bool
+	}{
+		{"cancelación con código de cancelación", owner, "Cancelado", "CUSTOMER_CHANGED_MIND", true},
+		{"cancelación con código de rechazo", owner, "Cancelado", "OUT_OF_STOCK", false},
+		{"rechazo con código compartido", admin, "Rechazado", "OTHER", true},
+		{"rechazo con código inexistente", admin, "Rechazado", "NO_EXISTE", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, _ := newService(t)
+			initOrder(t, svc, "ORD-REASON")
+
+			err := svc.UpdateStatus(ctx, "ORD-REASON", dto.UpdateStatusRequest{
+				Status: tt.status,
+				Data:   dto.TransitionDataDTO{ReasonCode: tt.code},
+			}, tt.actor)
+			if !tt.ok {
+				if !errors.Is(err, service.ErrInvalidReasonCode) {
+					t.Fatalf("se esperaba ErrInvalidReasonCode, se obtuvo %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// El código queda en el registro, aparte de los datos de la transición
+			got, err := svc.GetByOrderID(ctx, "ORD-REASON")
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := got.History[len(got.History)-1]
+			if last.Status != tt.status || last.ReasonCode != tt.code || last.Data != nil {
+				t.Fatalf("registro: %q, código %q, datos %+v", last.Status, last.ReasonCode, last.Data)
+			}
+		})
+	}
+}
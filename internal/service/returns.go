@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Estados de una devolución (sub-workflow posterior a la entrega)
+const (
+	ReturnRequested = "Devolución Solicitada"
+	ReturnApproved  = "Devolución Aprobada"
+	ReturnRejected  = "Devolución Rechazada"
+	ReturnReceived  = "Recibida en depósito"
+	ReturnRefunded  = "Reembolsada"
+)
+
+// Transiciones permitidas para las devoluciones (las hace un admin).
+// Rechazada y Reembolsada son finales.
+var returnTransitions = map[string][]string{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnRefunded},
+}
+
+func isReturnOpen(status string) bool {
+	return status != ReturnRejected && status != ReturnRefunded
+}
+
+var (
+	ErrReturnNotAllowed          = errors.New("la orden no admite devoluciones en su estado actual")
+	ErrReturnWindowExpired       = errors.New("venció el plazo para pedir la devolución")
+	ErrReturnAlreadyOpen         = errors.New("la orden ya tiene una devolución en curso")
+	ErrReturnNotFound            = errors.New("la orden no tiene esa devolución")
+	ErrInvalidReturnTransition   = errors.New("transición de estado inválida para la devolución")
+	ErrReturnReasonRequired      = errors.New("el motivo de la devolución es obligatorio")
+	ErrReturnArticleNotDelivered = errors.New("sólo se pueden devolver artículos entregados")
+)
+
+// deliveredAt devuelve cuándo la orden entró a su estado actual
+func deliveredAt(ord *model.OrderStatus) time.Time {
+	if rec := currentRecord(ord); rec != nil {
+		return rec.Timestamp
+	}
+	return ord.UpdatedAt
+}
+
+// RequestReturn abre una devolución sobre una orden entregada (sólo el dueño).
+// El estado de la orden y su historial de entrega no cambian.
+func (s *OrderStatusService) RequestReturn(ctx context.Context, orderID string, req dto.CreateReturnRequest, actorID string) (*model.OrderReturn, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, ErrReturnReasonRequired
+	}
+
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if ord.UserID != actorID {
+		return nil, ErrForbidden
+	}
+
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return nil, err
+	}
+	if !wf.IsReturnable(ord.Status) {
+		return nil, ErrReturnNotAllowed
+	}
+	if time.Since(deliveredAt(ord)) > s.policy.ReturnWindow {
+		return nil, ErrReturnWindowExpired
+	}
+	for _, r := range ord.Returns {
+		if isReturnOpen(r.Status) {
+			return nil, ErrReturnAlreadyOpen
+		}
+	}
+
+	var articles []string
+	for _, id := range req.Articles {
+		if contains(articles, id) {
+			continue
+		}
+		var item *model.LineItem
+		for i := range ord.Items {
+			if ord.Items[i].ArticleID == id {
+				item = &ord.Items[i]
+				break
+			}
+		}
+		if item == nil {
+			return nil, fmt.Errorf("%w: %s", ErrItemNotFound, id)
+		}
+		if item.Status != ItemDelivered {
+			return nil, fmt.Errorf("%w: %s está en %q", ErrReturnArticleNotDelivered, id, item.Status)
+		}
+		articles = append(articles, id)
+	}
+
+	now := time.Now()
+	ret := model.OrderReturn{
+		ReturnID: newID(),
+		Articles: articles,
+		Reason:   reason,
+		Status:   ReturnRequested,
+		History: []model.StatusRecord{
+			{
+				Status:    ReturnRequested,
+				Reason:    reason,
+				UserID:    actorID,
+				Timestamp: now,
+			},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.AddReturn(ctx, orderID, ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// UpdateReturnStatus avanza una devolución (sólo admin)
+func (s *OrderStatusService) UpdateReturnStatus(ctx context.Context, orderID, returnID string, req dto.UpdateReturnStatusRequest, actorID string) error {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	var ret *model.OrderReturn
+	for i := range ord.Returns {
+		if ord.Returns[i].ReturnID == returnID {
+			ret = &ord.Returns[i]
+			break
+		}
+	}
+	if ret == nil {
+		return ErrReturnNotFound
+	}
+
+	if ret.Status == req.Status {
+		return nil
+	}
+	if !contains(returnTransitions[ret.Status], req.Status) {
+		return ErrInvalidReturnTransition
+	}
+
+	record := model.StatusRecord{
+		Status:    req.Status,
+		Reason:    req.Reason,
+		UserID:    actorID,
+		Timestamp: time.Now(),
+	}
+	return s.repo.UpdateReturnStatus(ctx, orderID, returnID, ret.Status, req.Status, record)
+}
+
+// GetByReturnStatus lista las órdenes con alguna devolución en q.ReturnStatus
+// (por defecto, las pendientes de revisión)
+func (s *OrderStatusService) GetByReturnStatus(ctx context.Context, q model.OrderQuery, next string) (*OrderPage, error) {
+	if q.ReturnStatus == "" {
+		q.ReturnStatus = ReturnRequested
+	}
+	return s.ListOrders(ctx, q, next)
+}
@@ -0,0 +1,186 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+// deliveredOrder deja una orden entregada con A-1 y A-2 entregados y A-3 cancelado
+func deliveredOrder(t *testing.T, svc *service.OrderStatusService, orderID string) {
+	t.Helper()
+	ctx := context.Background()
+	shippedOrder(t, svc, orderID)
+	sh, err := svc.CreateShipment(ctx, orderID, shipmentRequest("A-1", "A-2"), admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateShipmentStatus(ctx, orderID, sh.ShipmentID, dto.UpdateShipmentStatusRequest{Status: service.ShipmentDelivered}, admin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestReturn(t *testing.T) {
+	tests := []struct {
+		name         string
+		notDelivered bool // la orden queda en "Enviado"
+		setup        func(t *testing.T, svc *service.OrderStatusService, orders *repository.MemoryOrderRepository)
+		actor        service.Actor
+		req          dto.CreateReturnRequest
+		wantErr      error
+		wantArticles []string
+	}{
+		{
+			name: "toda la orden",
+			req:  dto.CreateReturnRequest{Reason: "Llegó roto"},
+		},
+		{
+			name:         "artículos repetidos",
+			req:          dto.CreateReturnRequest{Reason: "Llegó roto", Articles: []string{"A-2", "A-1", "A-2"}},
+			wantArticles: []string{"A-2", "A-1"},
+		},
+		{
+			name:    "sin motivo",
+			req:     dto.CreateReturnRequest{Reason: "  "},
+			wantErr: service.ErrReturnReasonRequired,
+		},
+		{
+			name:    "otro usuario",
+			actor:   service.Actor{ID: "user-2"},
+			req:     dto.CreateReturnRequest{Reason: "Llegó roto"},
+			wantErr: service.ErrForbidden,
+		},
+		{
+			name:         "orden sin entregar",
+			notDelivered: true,
+			req:          dto.CreateReturnRequest{Reason: "Llegó roto"},
+			wantErr:      service.ErrReturnNotAllowed,
+		},
+		{
+			name: "plazo vencido",
+			setup: func(t *testing.T, _ *service.OrderStatusService, orders *repository.MemoryOrderRepository) {
+				ageLastRecord(t, orders, "ORD-RET", 48*time.Hour)
+			},
+			req:     dto.CreateReturnRequest{Reason: "Llegó roto"},
+			wantErr: service.ErrReturnWindowExpired,
+		},
+		{
+			name:    "artículo cancelado",
+			req:     dto.CreateReturnRequest{Reason: "Llegó roto", Articles: []string{"A-1", "A-3"}},
+			wantErr: service.ErrReturnArticleNotDelivered,
+		},
+		{
+			name:    "artículo inexistente",
+			req:     dto.CreateReturnRequest{Reason: "Llegó roto", Articles: []string{"Z-9"}},
+			wantErr: service.ErrItemNotFound,
+		},
+		{
+			name: "con otra en curso",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				if _, err := svc.RequestReturn(context.Background(), "ORD-RET", dto.CreateReturnRequest{Reason: "No era el talle"}, owner.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req:     dto.CreateReturnRequest{Reason: "Llegó roto"},
+			wantErr: service.ErrReturnAlreadyOpen,
+		},
+		{
+			name: "después de una rechazada",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				ctx := context.Background()
+				ret, err := svc.RequestReturn(ctx, "ORD-RET", dto.CreateReturnRequest{Reason: "No era el talle"}, owner.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := svc.UpdateReturnStatus(ctx, "ORD-RET", ret.ReturnID, dto.UpdateReturnStatusRequest{Status: service.ReturnRejected}, admin.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			req: dto.CreateReturnRequest{Reason: "Llegó roto"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			if tt.notDelivered {
+				shippedOrder(t, svc, "ORD-RET")
+			} else {
+				deliveredOrder(t, svc, "ORD-RET")
+			}
+			if tt.setup != nil {
+				tt.setup(t, svc, orders)
+			}
+			actor := owner
+			if tt.actor.ID != "" {
+				actor = tt.actor
+			}
+
+			ret, err := svc.RequestReturn(ctx, "ORD-RET", tt.req, actor.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if ret.Status != service.ReturnRequested || !slices.Equal(ret.Articles, tt.wantArticles) {
+				t.Fatalf("se esperaba una devolución solicitada con %v, se obtuvo %q %v", tt.wantArticles, ret.Status, ret.Articles)
+			}
+
+			// La orden sigue entregada
+			ord, err := orders.FindByOrderID(ctx, "ORD-RET")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ord.Status != "Entregado" {
+				t.Fatalf("la devolución cambió el estado de la orden a %q", ord.Status)
+			}
+		})
+	}
+}
+
+func TestUpdateReturnStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    []string // estados por los que pasa la devolución antes de probar
+		to      string
+		wantErr error
+	}{
+		{name: "aprobar", to: service.ReturnApproved},
+		{name: "rechazar", to: service.ReturnRejected},
+		{name: "recibir aprobada", from: []string{service.ReturnApproved}, to: service.ReturnReceived},
+		{name: "reembolsar recibida", from: []string{service.ReturnApproved, service.ReturnReceived}, to: service.ReturnRefunded},
+		{name: "reembolsar sin recibir", from: []string{service.ReturnApproved}, to: service.ReturnRefunded, wantErr: service.ErrInvalidReturnTransition},
+		{name: "rechazada es final", from: []string{service.ReturnRejected}, to: service.ReturnApproved, wantErr: service.ErrInvalidReturnTransition},
+		{name: "mismo estado no hace nada", from: []string{service.ReturnApproved}, to: service.ReturnApproved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, _ := newService(t)
+			deliveredOrder(t, svc, "ORD-RET")
+			ret, err := svc.RequestReturn(ctx, "ORD-RET", dto.CreateReturnRequest{Reason: "Llegó roto"}, owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, status := range tt.from {
+				if err := svc.UpdateReturnStatus(ctx, "ORD-RET", ret.ReturnID, dto.UpdateReturnStatusRequest{Status: status}, admin.ID); err != nil {
+					t.Fatalf("pasar a %q: %v", status, err)
+				}
+			}
+
+			err = svc.UpdateReturnStatus(ctx, "ORD-RET", ret.ReturnID, dto.UpdateReturnStatusRequest{Status: tt.to}, admin.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+		})
+	}
+}
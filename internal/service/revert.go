@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/model"
+)
+
+var (
+	ErrNothingToRevert         = errors.New("la orden no tiene una transición para revertir")
+	ErrRevertWindowExpired     = errors.New("venció el plazo para revertir la última transición")
+	ErrRevertCustomerNotified  = errors.New("no se puede revertir: el cliente ya fue notificado de ese estado")
+	ErrJustificationRequired   = errors.New("la justificación es obligatoria")
+	ErrRevertTargetUnavailable = errors.New("el estado anterior ya no existe en el workflow")
+	ErrRevertShipped           = fmt.Errorf("no se puede revertir: la orden ya tiene envíos o repartidor asignado y dejaría de estar en %q", ShipmentShipped)
+)
+
+// RevertLastTransition deshace la última transición de la orden agregando un registro
+// compensatorio que vuelve al estado anterior. El historial nunca se borra.
+// Sólo se permite dentro de policy.RevertWindow, no sobre otra reversión, nunca
+// desde un estado del que el cliente ya fue notificado, y no saca de "Enviado" a una
+// orden con envíos o repartidor (quedarían colgados de un estado anterior al despacho).
+// El SLA vuelve a correr desde donde estaba antes de la transición revertida.
+func (s *OrderStatusService) RevertLastTransition(ctx context.Context, orderID, justification, actorID string) error {
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		return ErrJustificationRequired
+	}
+
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	// Las asignaciones de repartidor no son transiciones: se revierte la última transición de estado
+	transitions := slices.DeleteFunc(slices.Clone(ord.History), isAssignment)
+	n := len(transitions)
+	if n < 2 {
+		return ErrNothingToRevert
+	}
+	last, previous := transitions[n-1], transitions[n-2]
+	if last.Revert {
+		return ErrNothingToRevert
+	}
+	if time.Since(last.Timestamp) > s.policy.RevertWindow {
+		return ErrRevertWindowExpired
+	}
+
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return err
+	}
+	if wf.NotifiesCustomer(ord.Status) {
+		return ErrRevertCustomerNotified
+	}
+	if previous.Status != OnHoldStatus && !wf.IsValidState(previous.Status) {
+		return ErrRevertTargetUnavailable
+	}
+	target := previous.Status
+	if target == OnHoldStatus {
+		target = previous.HeldFrom
+	}
+	if target != ShipmentShipped && (len(ord.Shipments) > 0 || ord.CourierID != "") {
+		return ErrRevertShipped
+	}
+
+	now := time.Now()
+	record := model.StatusRecord{
+		Status:       previous.Status,
+		Reason:       justification,
+		UserID:       actorID,
+		Timestamp:    now,
+		Revert:       true,
+		RevertedFrom: ord.Status,
+		HeldFrom:     previous.HeldFrom, // si se vuelve a "En Espera", se reanuda igual que antes
+		Current:      true,
+	}
+	return s.applyTransition(ctx, ord, record, revertSLASince(ord, last, previous, now))
+}
+
+// revertSLASince devuelve el inicio del SLA al volver a previous: el que quedó al
+// aplicarlo (registros viejos: su fecha), con la pausa descontada como en holdOrResume.
+func revertSLASince(ord *model.OrderStatus, last, previous model.StatusRecord, now time.Time) time.Time {
+	current := ord.SLASince
+	if current.IsZero() {
+		current = ord.UpdatedAt
+	}
+	switch {
+	case last.Status == OnHoldStatus:
+		// Se deshace una pausa: el SLA no cambió al pausar, se descuenta lo que duró
+		return current.Add(now.Sub(last.Timestamp))
+	case previous.Status == OnHoldStatus:
+		// Se deshace una reanudación: el SLA ya descuenta esa parte de la pausa y la
+		// próxima reanudación descuenta el resto (desde este registro)
+		return current
+	case !previous.SLASince.IsZero():
+		return previous.SLASince
+	default:
+		return previous.Timestamp
+	}
+}
@@ -0,0 +1,220 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+var shippedData = dto.TransitionDataDTO{Carrier: "Andreani", TrackingNumber: "AR123"}
+
+// moveTo pasa la orden por los estados indicados como admin
+func moveTo(t *testing.T, svc *service.OrderStatusService, orderID string, reqs ...dto.UpdateStatusRequest) {
+	t.Helper()
+	for _, req := range reqs {
+		if err := svc.UpdateStatus(context.Background(), orderID, req, admin); err != nil {
+			t.Fatalf("pasar a %q: %v", req.Status, err)
+		}
+	}
+}
+
+// ageLastRecord corre hacia atrás la fecha del último registro del historial
+func ageLastRecord(t *testing.T, orders *repository.MemoryOrderRepository, orderID string, d time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	ord, err := orders.FindByOrderID(ctx, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ord.History[len(ord.History)-1].Timestamp = ord.History[len(ord.History)-1].Timestamp.Add(-d)
+	if err := orders.ReplaceHistory(ctx, orderID, ord.Version, ord.Status, ord.History); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevertLastTransition(t *testing.T) {
+	tests := []struct {
+		name          string
+		setup         func(t *testing.T, svc *service.OrderStatusService, orders *repository.MemoryOrderRepository)
+		justification string
+		wantErr       error
+		wantStatus    string // estado después de revertir (si no hay error)
+	}{
+		{
+			name: "dentro del plazo",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: "En Preparación"})
+			},
+			justification: "Pasada por error",
+			wantStatus:    "Pendiente",
+		},
+		{
+			name: "sin justificación",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: "En Preparación"})
+			},
+			justification: "   ",
+			wantErr:       service.ErrJustificationRequired,
+		},
+		{
+			name:          "sin transición previa",
+			setup:         func(*testing.T, *service.OrderStatusService, *repository.MemoryOrderRepository) {},
+			justification: "Pasada por error",
+			wantErr:       service.ErrNothingToRevert,
+		},
+		{
+			name: "plazo vencido",
+			setup: func(t *testing.T, svc *service.OrderStatusService, orders *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: "En Preparación"})
+				ageLastRecord(t, orders, "ORD-REV", 2*time.Hour)
+			},
+			justification: "Pasada por error",
+			wantErr:       service.ErrRevertWindowExpired,
+		},
+		{
+			name: "sobre otra reversión",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: "En Preparación"})
+				if err := svc.RevertLastTransition(context.Background(), "ORD-REV", "Pasada por error", admin.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			justification: "Otra vez",
+			wantErr:       service.ErrNothingToRevert,
+		},
+		{
+			name: "cliente notificado",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: "Rechazado", Data: dto.TransitionDataDTO{ReasonCode: "OUT_OF_STOCK"}})
+			},
+			justification: "Pasada por error",
+			wantErr:       service.ErrRevertCustomerNotified,
+		},
+		{
+			name: "fuera de Enviado con repartidor",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV",
+					dto.UpdateStatusRequest{Status: "En Preparación"},
+					dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+				if err := svc.AssignCourier(context.Background(), "ORD-REV", "courier-1", "", admin.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			justification: "Pasada por error",
+			wantErr:       service.ErrRevertShipped,
+		},
+		{
+			name: "pausa en Enviado con repartidor",
+			setup: func(t *testing.T, svc *service.OrderStatusService, _ *repository.MemoryOrderRepository) {
+				moveTo(t, svc, "ORD-REV",
+					dto.UpdateStatusRequest{Status: "En Preparación"},
+					dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+				if err := svc.AssignCourier(context.Background(), "ORD-REV", "courier-1", "", admin.ID); err != nil {
+					t.Fatal(err)
+				}
+				moveTo(t, svc, "ORD-REV", dto.UpdateStatusRequest{Status: service.OnHoldStatus})
+			},
+			justification: "Pausada por error",
+			wantStatus:    "Enviado",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			initOrder(t, svc, "ORD-REV")
+			tt.setup(t, svc, orders)
+			before, err := orders.FindByOrderID(ctx, "ORD-REV")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = svc.RevertLastTransition(ctx, "ORD-REV", tt.justification, admin.ID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+				}
+				after, err := orders.FindByOrderID(ctx, "ORD-REV")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if after.Version != before.Version {
+					t.Fatalf("la reversión rechazada modificó la orden (versión %d → %d)", before.Version, after.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RevertLastTransition: %v", err)
+			}
+
+			// El historial no se borra: se agrega un registro compensatorio
+			after, err := orders.FindByOrderID(ctx, "ORD-REV")
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := after.History[len(after.History)-1]
+			if after.Status != tt.wantStatus || len(after.History) != len(before.History)+1 {
+				t.Fatalf("se esperaba %q con un registro más, se obtuvo %q con %d registros", tt.wantStatus, after.Status, len(after.History))
+			}
+			if !last.Revert || last.RevertedFrom != before.Status || last.Reason != tt.justification {
+				t.Fatalf("registro de reversión inesperado: %+v", last)
+			}
+		})
+	}
+}
+
+func TestRevertRestoresSLA(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("vuelve al SLA del estado anterior", func(t *testing.T) {
+		svc, orders := newService(t)
+		initOrder(t, svc, "ORD-SLA")
+		moveTo(t, svc, "ORD-SLA", dto.UpdateStatusRequest{Status: "En Preparación"})
+		prepared, err := orders.FindByOrderID(ctx, "ORD-SLA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		moveTo(t, svc, "ORD-SLA", dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+
+		if err := svc.RevertLastTransition(ctx, "ORD-SLA", "Despachada por error", admin.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, err := orders.FindByOrderID(ctx, "ORD-SLA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.SLASince.Equal(prepared.SLASince) {
+			t.Fatalf("se esperaba el SLA de En Preparación (%v), se obtuvo %v", prepared.SLASince, got.SLASince)
+		}
+	})
+
+	t.Run("deshacer una pausa descuenta lo que duró", func(t *testing.T) {
+		svc, orders := newService(t)
+		initOrder(t, svc, "ORD-SLA")
+		moveTo(t, svc, "ORD-SLA", dto.UpdateStatusRequest{Status: "En Preparación"})
+		prepared, err := orders.FindByOrderID(ctx, "ORD-SLA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		moveTo(t, svc, "ORD-SLA", dto.UpdateStatusRequest{Status: service.OnHoldStatus})
+		time.Sleep(10 * time.Millisecond)
+
+		if err := svc.RevertLastTransition(ctx, "ORD-SLA", "Pausada por error", admin.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, err := orders.FindByOrderID(ctx, "ORD-SLA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "En Preparación" || got.SLASince.Sub(prepared.SLASince) < 10*time.Millisecond {
+			t.Fatalf("se esperaba En Preparación con el SLA corrido por la pausa, se obtuvo %q %v (antes %v)", got.Status, got.SLASince, prepared.SLASince)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+const (
+	scheduleLockName  = "scheduled_transitions"
+	scheduleBatchSize = 500
+	// Si el worker se cae con una transición en processing, otro la vuelve a tomar
+	// cuando pasa este tiempo desde claimed_at
+	scheduleClaimTTL = 5 * time.Minute
+)
+
+var (
+	ErrScheduleNotPending = errors.New("la transición programada ya no está pendiente")
+	ErrScheduleInPast     = errors.New("la fecha de la transición programada tiene que ser futura")
+)
+
+// Acceso a scheduled_transitions (lo implementa MongoScheduleRepository)
+type ScheduleRepository interface {
+	Save(ctx context.Context, st *model.ScheduledTransition) error
+	FindByID(ctx context.Context, orderID, scheduleID string) (*model.ScheduledTransition, error)
+	FindByOrderID(ctx context.Context, orderID string) ([]*model.ScheduledTransition, error)
+	// FindDue devuelve las pendientes vencidas y las que quedaron en processing con
+	// claimed_at anterior a staleBefore (toma abandonada)
+	FindDue(ctx context.Context, now, staleBefore time.Time, limit int64) ([]*model.ScheduledTransition, error)
+	// FindPendingByStatus devuelve las que todavía no se aplicaron (pendientes o en proceso) hacia ese estado
+	FindPendingByStatus(ctx context.Context, status string) ([]*model.ScheduledTransition, error)
+	// Claim pasa la transición a processing (claimed_at = ahora) si está pendiente o si
+	// su toma es anterior a staleBefore. Devuelve false si la tiene otro proceso.
+	Claim(ctx context.Context, scheduleID string, staleBefore time.Time) (bool, error)
+	UpdateState(ctx context.Context, scheduleID, from, to, result string) (bool, error)
+}
+
+// TransitionScheduler guarda transiciones con fecha futura y las aplica al vencer,
+// mediante UpdateStatus con el admin que las programó (con rol admin).
+type TransitionScheduler struct {
+	orders   *OrderStatusService
+	repo     ScheduleRepository
+	locks    LockRepository
+	interval time.Duration
+	owner    string
+}
+
+func NewTransitionScheduler(orders *OrderStatusService, repo ScheduleRepository, locks LockRepository, interval time.Duration) *TransitionScheduler {
+	host, _ := os.Hostname()
+	return &TransitionScheduler{
+		orders:   orders,
+		repo:     repo,
+		locks:    locks,
+		interval: interval,
+		owner:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Schedule programa una transición. Acá sólo se valida lo que no puede cambiar hasta
+// la fecha (la orden existe, no es final, el estado destino existe); el resto se
+// valida al aplicarla.
+func (s *TransitionScheduler) Schedule(ctx context.Context, orderID string, req dto.ScheduleTransitionRequest, actorID string) (*model.ScheduledTransition, error) {
+	if !req.DueAt.After(time.Now()) {
+		return nil, ErrScheduleInPast
+	}
+
+	ord, err := s.orders.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := s.orders.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return nil, err
+	}
+	if wf.IsFinal(ord.Status) {
+		return nil, ErrFinalState
+	}
+	if req.Status != OnHoldStatus && !wf.IsValidState(req.Status) {
+		return nil, ErrInvalidTransition
+	}
+	if req.Data.ReasonCode != "" {
+		if err := s.orders.reasons.Check(req.Data.ReasonCode, req.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	st := &model.ScheduledTransition{
+		ScheduleID: newID(),
+		OrderID:    orderID,
+		FromStatus: ord.Status,
+		Status:     req.Status,
+		Reason:     req.Reason,
+		ReasonCode: req.Data.ReasonCode,
+		Data:       dtoToModelTransitionData(req.Data),
+		DueAt:      req.DueAt.UTC(),
+		State:      model.SchedulePending,
+		CreatedBy:  actorID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	return st, s.repo.Save(ctx, st)
+}
+
+// List devuelve las transiciones programadas de la orden (todas, con su resultado)
+func (s *TransitionScheduler) List(ctx context.Context, orderID string) ([]*model.ScheduledTransition, error) {
+	if _, err := s.orders.repo.FindByOrderID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	out, err := s.repo.FindByOrderID(ctx, orderID)
+	if out == nil {
+		out = []*model.ScheduledTransition{}
+	}
+	return out, err
+}
+
+// Cancel cancela una transición programada que todavía no se aplicó
+func (s *TransitionScheduler) Cancel(ctx context.Context, orderID, scheduleID, actorID string) error {
+	st, err := s.repo.FindByID(ctx, orderID, scheduleID)
+	if err != nil {
+		return err
+	}
+	if st.State != model.SchedulePending {
+		return ErrScheduleNotPending
+	}
+	ok, err := s.repo.UpdateState(ctx, scheduleID, model.SchedulePending, model.ScheduleCancelled, "Cancelada por "+actorID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrScheduleNotPending
+	}
+	return nil
+}
+
+// Start ejecuta el worker en segundo plano hasta que se cancele ctx.
+func (s *TransitionScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RunOnce(ctx); err != nil {
+					log.Println("❌ Transiciones programadas:", err)
+				}
+			}
+		}
+	}()
+	log.Printf("⏱ Worker de transiciones programadas iniciado (cada %s)", s.interval)
+}
+
+// RunOnce aplica las transiciones vencidas, sólo si esta réplica tiene el lock.
+func (s *TransitionScheduler) RunOnce(ctx context.Context) error {
+	ok, err := s.locks.Acquire(ctx, scheduleLockName, s.owner, 2*s.interval)
+	if err != nil || !ok {
+		return err
+	}
+
+	now := time.Now().UTC()
+	due, err := s.repo.FindDue(ctx, now, now.Add(-scheduleClaimTTL), scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, st := range due {
+		s.apply(ctx, st)
+	}
+	return nil
+}
+
+// apply toma la transición (para que no se cancele mientras tanto), la vuelve a
+// validar con UpdateStatus y registra el resultado. Una transición que quedó en
+// processing (el worker se cayó a mitad) se vuelve a tomar al vencer la toma.
+func (s *TransitionScheduler) apply(ctx context.Context, st *model.ScheduledTransition) {
+	ok, err := s.repo.Claim(ctx, st.ScheduleID, time.Now().UTC().Add(-scheduleClaimTTL))
+	if err != nil || !ok {
+		return
+	}
+
+	state, result := model.ScheduleApplied, ""
+	if st.State == model.ScheduleProcessing && s.alreadyApplied(ctx, st) {
+		result = "ya se había aplicado; el worker anterior no llegó a registrar el resultado"
+	} else if skip := s.check(ctx, st); skip != "" {
+		state, result = model.ScheduleSkipped, skip
+	} else {
+		data := dto.TransitionDataDTO{ReasonCode: st.ReasonCode}
+		if st.Data != nil {
+			data.Carrier, data.TrackingNumber = st.Data.Carrier, st.Data.TrackingNumber
+		}
+		err = s.orders.UpdateStatus(ctx, st.OrderID, dto.UpdateStatusRequest{
+			Status: st.Status,
+			Reason: st.Reason,
+			Data:   data,
+		}, Actor{ID: st.CreatedBy, Roles: []string{RoleAdmin}})
+		if err != nil {
+			state, result = model.ScheduleSkipped, err.Error()
+		}
+	}
+
+	if _, err := s.repo.UpdateState(ctx, st.ScheduleID, model.ScheduleProcessing, state, result); err != nil {
+		log.Printf("❌ Transición programada %s: %v", st.ScheduleID, err)
+		return
+	}
+	log.Printf("⏱ Transición programada %s (orden %s → %s): %s %s", st.ScheduleID, st.OrderID, st.Status, state, result)
+}
+
+// alreadyApplied indica si el registro actual de la orden es el de esta transición
+// (la toma anterior llegó a aplicarla pero no a marcarla applied).
+func (s *TransitionScheduler) alreadyApplied(ctx context.Context, st *model.ScheduledTransition) bool {
+	ord, err := s.orders.repo.FindByOrderID(ctx, st.OrderID)
+	if err != nil {
+		return false
+	}
+	rec := currentRecord(ord)
+	return rec != nil && rec.Status == st.Status && rec.UserID == st.CreatedBy && rec.Reason == st.Reason
+}
+
+// check devuelve por qué no se aplica la transición, o "" si sigue vigente:
+// si la orden ya no está en el estado en el que estaba al programarla, se omite.
+func (s *TransitionScheduler) check(ctx context.Context, st *model.ScheduledTransition) string {
+	ord, err := s.orders.repo.FindByOrderID(ctx, st.OrderID)
+	if err != nil {
+		return err.Error()
+	}
+	if ord.Status != st.FromStatus {
+		return fmt.Sprintf("la orden pasó de %q a %q desde que se programó", st.FromStatus, ord.Status)
+	}
+	return ""
+}
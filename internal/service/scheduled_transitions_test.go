@@ -0,0 +1,209 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+func TestScheduleTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		final   bool // la orden está en un estado final
+		req     dto.ScheduleTransitionRequest
+		wantErr error
+	}{
+		{
+			name: "vigente",
+			req:  dto.ScheduleTransitionRequest{Status: "En Preparación", DueAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name: "en espera",
+			req:  dto.ScheduleTransitionRequest{Status: service.OnHoldStatus, DueAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name:    "fecha pasada",
+			req:     dto.ScheduleTransitionRequest{Status: "En Preparación", DueAt: time.Now().Add(-time.Minute)},
+			wantErr: service.ErrScheduleInPast,
+		},
+		{
+			name:    "estado inexistente",
+			req:     dto.ScheduleTransitionRequest{Status: "Perdido", DueAt: time.Now().Add(time.Hour)},
+			wantErr: service.ErrInvalidTransition,
+		},
+		{
+			name: "código de otro estado",
+			req: dto.ScheduleTransitionRequest{
+				Status: "Rechazado",
+				Data:   dto.TransitionDataDTO{ReasonCode: "CUSTOMER_CHANGED_MIND"},
+				DueAt:  time.Now().Add(time.Hour),
+			},
+			wantErr: service.ErrInvalidReasonCode,
+		},
+		{
+			name:    "orden en estado final",
+			final:   true,
+			req:     dto.ScheduleTransitionRequest{Status: "En Preparación", DueAt: time.Now().Add(time.Hour)},
+			wantErr: service.ErrFinalState,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, _ := newService(t)
+			initOrder(t, svc, "ORD-SCH")
+			if tt.final {
+				moveTo(t, svc, "ORD-SCH", dto.UpdateStatusRequest{Status: "Rechazado", Data: rejected})
+			}
+			scheduler := service.NewTransitionScheduler(svc, repository.NewMemoryScheduleRepository(), repository.NewMemoryLockRepository(), time.Minute)
+
+			st, err := scheduler.Schedule(ctx, "ORD-SCH", tt.req, admin.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			if err == nil && (st.State != model.SchedulePending || st.FromStatus != "Pendiente") {
+				t.Fatalf("se esperaba pendiente desde Pendiente, se obtuvo %q desde %q", st.State, st.FromStatus)
+			}
+		})
+	}
+}
+
+func TestScheduledTransitionsRunOnce(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		at := time.Now().UTC().Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		name       string
+		st         model.ScheduledTransition
+		moved      string // estado al que pasa la orden después de programar ("" = sigue en Pendiente)
+		wantState  string
+		wantStatus string
+		wantRecs   int // registros del historial de la orden al terminar
+	}{
+		{
+			name:       "vencida",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(-time.Minute), State: model.SchedulePending},
+			wantState:  model.ScheduleApplied,
+			wantStatus: "En Preparación",
+			wantRecs:   2,
+		},
+		{
+			name:       "todavía no vence",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(time.Hour), State: model.SchedulePending},
+			wantState:  model.SchedulePending,
+			wantStatus: "Pendiente",
+			wantRecs:   1,
+		},
+		{
+			name:       "la orden cambió de estado",
+			st:         model.ScheduledTransition{Status: "Rechazado", ReasonCode: "OUT_OF_STOCK", DueAt: time.Now().Add(-time.Minute), State: model.SchedulePending},
+			moved:      "En Preparación",
+			wantState:  model.ScheduleSkipped,
+			wantStatus: "En Preparación",
+			wantRecs:   2,
+		},
+		{
+			name:       "inválida al aplicarla",
+			st:         model.ScheduledTransition{Status: "Rechazado", DueAt: time.Now().Add(-time.Minute), State: model.SchedulePending},
+			wantState:  model.ScheduleSkipped,
+			wantStatus: "Pendiente",
+			wantRecs:   1,
+		},
+		{
+			name:       "cancelada",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(-time.Minute), State: model.ScheduleCancelled},
+			wantState:  model.ScheduleCancelled,
+			wantStatus: "Pendiente",
+			wantRecs:   1,
+		},
+		{
+			name:       "toma abandonada",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(-time.Hour), State: model.ScheduleProcessing, ClaimedAt: ago(10 * time.Minute)},
+			wantState:  model.ScheduleApplied,
+			wantStatus: "En Preparación",
+			wantRecs:   2,
+		},
+		{
+			name:       "toma abandonada ya aplicada",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(-time.Hour), State: model.ScheduleProcessing, ClaimedAt: ago(10 * time.Minute)},
+			moved:      "En Preparación",
+			wantState:  model.ScheduleApplied,
+			wantStatus: "En Preparación",
+			wantRecs:   2,
+		},
+		{
+			name:       "tomada por otro worker",
+			st:         model.ScheduledTransition{Status: "En Preparación", DueAt: time.Now().Add(-time.Hour), State: model.ScheduleProcessing, ClaimedAt: ago(time.Minute)},
+			wantState:  model.ScheduleProcessing,
+			wantStatus: "Pendiente",
+			wantRecs:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			initOrder(t, svc, "ORD-SCH")
+			schedules := repository.NewMemoryScheduleRepository()
+
+			st := tt.st
+			st.ScheduleID, st.OrderID, st.FromStatus = "SCH-1", "ORD-SCH", "Pendiente"
+			st.Reason, st.CreatedBy = "Programada", admin.ID
+			if err := schedules.Save(ctx, &st); err != nil {
+				t.Fatal(err)
+			}
+			if tt.moved != "" {
+				moveTo(t, svc, "ORD-SCH", dto.UpdateStatusRequest{Status: tt.moved, Reason: "Programada"})
+			}
+
+			scheduler := service.NewTransitionScheduler(svc, schedules, repository.NewMemoryLockRepository(), time.Minute)
+			if err := scheduler.RunOnce(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := schedules.FindByID(ctx, "ORD-SCH", "SCH-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ord, err := orders.FindByOrderID(ctx, "ORD-SCH")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != tt.wantState || ord.Status != tt.wantStatus || len(ord.History) != tt.wantRecs {
+				t.Fatalf("se esperaba %s con la orden en %q (%d registros), se obtuvo %s (%q) con %q (%d registros)",
+					tt.wantState, tt.wantStatus, tt.wantRecs, got.State, got.Result, ord.Status, len(ord.History))
+			}
+			if got.State == model.ScheduleSkipped && got.Result == "" {
+				t.Fatal("la transición omitida no dice por qué")
+			}
+		})
+	}
+}
+
+func TestCancelScheduledTransition(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	initOrder(t, svc, "ORD-SCH")
+	scheduler := service.NewTransitionScheduler(svc, repository.NewMemoryScheduleRepository(), repository.NewMemoryLockRepository(), time.Minute)
+	st, err := scheduler.Schedule(ctx, "ORD-SCH", dto.ScheduleTransitionRequest{Status: "En Preparación", DueAt: time.Now().Add(time.Hour)}, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.Cancel(ctx, "ORD-SCH", st.ScheduleID, admin.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := scheduler.Cancel(ctx, "ORD-SCH", st.ScheduleID, admin.ID); !errors.Is(err, service.ErrScheduleNotPending) {
+		t.Fatalf("cancelar dos veces: se esperaba ErrScheduleNotPending, se obtuvo %v", err)
+	}
+}
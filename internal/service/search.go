@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"order-status-service-2/internal/model"
+)
+
+// Resultados que se pueden recorrer en una búsqueda: la relevancia se calcula
+// en cada pedido, así que las páginas profundas son caras
+const maxSearchResults = 1000
+
+var ErrEmptySearch = errors.New("el texto a buscar es obligatorio")
+
+// searchToken es el contenido del token "next" de una búsqueda
+type searchToken struct {
+	Query  string `json:"q"`
+	Offset int    `json:"o"`
+}
+
+// SearchOrders busca órdenes por prefijo del ID y por texto en el ID, el usuario
+// y los datos de envío (dirección, ciudad, provincia, comentarios...).
+// Los resultados vienen de más a menos relevantes, paginados con el token next.
+func (s *OrderStatusService) SearchOrders(ctx context.Context, text string, limit int, next string) (*OrderPage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptySearch
+	}
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	offset := 0
+	if next != "" {
+		b, err := base64.RawURLEncoding.DecodeString(next)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		var t searchToken
+		if err := json.Unmarshal(b, &t); err != nil || t.Query != text || t.Offset < 0 {
+			return nil, ErrInvalidCursor
+		}
+		offset = t.Offset
+	}
+	if offset >= maxSearchResults {
+		return &OrderPage{Orders: []*model.OrderStatus{}}, nil
+	}
+
+	// Se pide uno de más para saber si hay otra página
+	orders, err := s.repo.Search(ctx, text, offset+limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{Orders: []*model.OrderStatus{}}
+	if offset < len(orders) {
+		page.Orders = orders[offset:min(offset+limit, len(orders))]
+	}
+	if len(orders) > offset+limit && offset+limit < maxSearchResults {
+		b, _ := json.Marshal(searchToken{Query: text, Offset: offset + limit})
+		page.Next = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return page, nil
+}
@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/service"
+)
+
+// searchableOrders crea órdenes que coinciden con "mendoza" por prefijo del ID y
+// en distintos campos del envío
+func searchableOrders(t *testing.T, svc *service.OrderStatusService) {
+	t.Helper()
+	for id, shipping := range map[string]dto.ShippingDTO{
+		"mendoza-1": {AddressLine1: "Colón 100", City: "Córdoba"},
+		"S-1":       {AddressLine1: "Belgrano 200", City: "Mendoza", Province: "Mendoza"},
+		"S-2":       {AddressLine1: "Perú 300", City: "Godoy Cruz", Comments: "Dejar en portería, Mendoza"},
+		"S-3":       {AddressLine1: "Sarmiento 400", City: "Mendoza"},
+		"S-4":       {AddressLine1: "Caseros 500", City: "Salta"},
+	} {
+		if _, err := svc.InitOrderStatus(context.Background(), id, owner.ID, "", shipping, nil, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSearchOrdersPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		pages [][]string
+	}{
+		{
+			name:  "prefijo primero y después por relevancia",
+			text:  "mendoza",
+			limit: 10,
+			pages: [][]string{{"mendoza-1", "S-1", "S-3", "S-2"}},
+		},
+		{
+			name:  "de a uno",
+			text:  "mendoza",
+			limit: 1,
+			pages: [][]string{{"mendoza-1"}, {"S-1"}, {"S-3"}, {"S-2"}},
+		},
+		{
+			name:  "el prefijo repetido en el texto no acorta la página",
+			text:  "mendoza",
+			limit: 2,
+			pages: [][]string{{"mendoza-1", "S-1"}, {"S-3", "S-2"}},
+		},
+		{
+			name:  "página justa no deja token",
+			text:  "S-",
+			limit: 4,
+			pages: [][]string{{"S-1", "S-2", "S-3", "S-4"}},
+		},
+		{
+			name:  "sin tildes ni mayúsculas",
+			text:  "cordoba",
+			limit: 10,
+			pages: [][]string{{"mendoza-1"}},
+		},
+		{
+			name:  "sin resultados",
+			text:  "tucuman",
+			limit: 10,
+			pages: [][]string{{}},
+		},
+	}
+
+	svc, _ := newService(t)
+	searchableOrders(t, svc)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			next := ""
+			for {
+				page, err := svc.SearchOrders(context.Background(), tt.text, tt.limit, next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, o := range page.Orders {
+					ids = append(ids, o.OrderID)
+				}
+				got = append(got, ids)
+				if page.Next == "" {
+					break
+				}
+				next = page.Next
+			}
+			if !slices.EqualFunc(got, tt.pages, slices.Equal) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.pages, got)
+			}
+		})
+	}
+}
+
+func TestSearchOrdersCursor(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	searchableOrders(t, svc)
+	first, err := svc.SearchOrders(ctx, "mendoza", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		next    string
+		wantErr error
+	}{
+		{name: "misma búsqueda", text: "mendoza", next: first.Next},
+		{name: "espacios alrededor", text: "  mendoza ", next: first.Next},
+		{name: "token de otra búsqueda", text: "salta", next: first.Next, wantErr: service.ErrInvalidCursor},
+		{name: "token roto", text: "mendoza", next: "%%%", wantErr: service.ErrInvalidCursor},
+		{name: "offset negativo", text: "mendoza", next: "eyJxIjoibWVuZG96YSIsIm8iOi0xfQ", wantErr: service.ErrInvalidCursor}, // {"q":"mendoza","o":-1}
+		{name: "sin texto", text: "  ", wantErr: service.ErrEmptySearch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SearchOrders(ctx, tt.text, 1, tt.next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"order-status-service-2/internal/dto"
@@ -13,10 +14,31 @@ import (
 type OrderRepository interface {
 	Save(ctx context.Context, o *model.OrderStatus) error
 	FindByOrderID(ctx context.Context, orderID string) (*model.OrderStatus, error)
-	UpdateStatus(ctx context.Context, orderID, status string, record model.StatusRecord) error
-	FindAll(ctx context.Context) ([]*model.OrderStatus, error)
-	FindByStatus(ctx context.Context, status string) ([]*model.OrderStatus, error)
-	FindByUserID(ctx context.Context, userID string) ([]*model.OrderStatus, error)
+	// UpdateStatus falla (sin escribir) si la orden ya no está en expectedVersion.
+	// slaSince es el inicio del SLA del estado nuevo (record.Timestamp, salvo al pausar o reanudar)
+	UpdateStatus(ctx context.Context, orderID string, expectedVersion int64, status string, slaSince time.Time, record model.StatusRecord) error
+	// FindPage devuelve hasta q.Limit órdenes, después de q.After (ver model.OrderQuery)
+	FindPage(ctx context.Context, q model.OrderQuery) ([]*model.OrderStatus, error)
+	// Search devuelve hasta limit órdenes que matchean el texto, de más a menos relevante
+	Search(ctx context.Context, text string, limit int) ([]*model.OrderStatus, error)
+	CountByStatus(ctx context.Context, workflow, status string) (int64, error)
+	// CountHeldFrom cuenta las órdenes en espera que al reanudarse vuelven a status
+	CountHeldFrom(ctx context.Context, workflow, status string) (int64, error)
+	// UpdateItemStatus, UpdateShipmentStatus y UpdateReturnStatus fallan con
+	// repository.ErrVersionConflict (sin escribir) si el elemento ya no está en from
+	UpdateItemStatus(ctx context.Context, orderID, articleID, from, status string, record model.StatusRecord) error
+	// AddShipment falla con repository.ErrVersionConflict si la orden ya no está en expectedVersion
+	AddShipment(ctx context.Context, orderID string, expectedVersion int64, shipment model.Shipment, itemStatus string, itemRecord model.StatusRecord) error
+	UpdateShipmentStatus(ctx context.Context, orderID, shipmentID string, articles []string, from, status string, record model.StatusRecord) error
+	AddReturn(ctx context.Context, orderID string, ret model.OrderReturn) error
+	UpdateReturnStatus(ctx context.Context, orderID, returnID, from, status string, record model.StatusRecord) error
+	AssignCourier(ctx context.Context, orderID string, expectedVersion int64, courierID string, record model.StatusRecord) error
+	AddDeliveryAttempt(ctx context.Context, orderID string, expectedVersion int64, attempt model.DeliveryAttempt) error
+}
+
+// Fuente del grafo de estados vigente de cada workflow (lo implementa StateCatalogService)
+type WorkflowProvider interface {
+	Workflow(ctx context.Context, name string) (*Workflow, error)
 }
 
 func dtoToModelShipping(in dto.ShippingDTO) model.Shipping {
@@ -36,54 +58,44 @@ var (
 	ErrInvalidTransition  = errors.New("transición de estado inválida")
 	ErrFinalState         = errors.New("no se puede cambiar el estado de una orden en estado final")
 	ErrOrderAlreadyExists = errors.New("la orden ya fue inicializada previamente")
+	ErrNoInitialState     = errors.New("el workflow no tiene estado inicial")
+	ErrStaleVersion       = errors.New("la orden fue modificada después de la versión indicada en If-Match")
 )
 
-type OrderStatusService struct {
-	repo OrderRepository
-}
-
-func NewOrderStatusService(r OrderRepository) *OrderStatusService {
-	return &OrderStatusService{repo: r}
-}
-
-// Estados válidos (por nombre). No hay catálogo en BD.
-var validStates = map[string]bool{
-	"Pendiente":      true,
-	"En Preparación": true,
-	"Enviado":        true,
-	"Entregado":      true,
-	"Cancelado":      true,
-	"Rechazado":      true,
+// Parámetros de negocio configurables (ver config.Load)
+type OrderPolicy struct {
+	// Tiempo durante el cual un admin puede revertir la última transición
+	RevertWindow time.Duration
+	// Tiempo, desde la entrega, durante el cual el dueño puede pedir una devolución
+	ReturnWindow time.Duration
+	// Órdenes que se procesan en paralelo en una actualización masiva
+	BulkConcurrency int
+	// Tamaño máximo, en bytes, de cada archivo de una constancia de entrega
+	ProofMaxFileSize int64
+	// Intentos de entrega fallidos tras los que la orden vuelve al remitente
+	MaxDeliveryAttempts int
 }
 
-func isValidState(s string) bool {
-	return validStates[s]
-}
-
-// Transiciones permitidas (hardcodeadas por nombre) para admin y user
-var adminTransitions = map[string][]string{
-	"Pendiente":      {"En Preparación", "Rechazado"},
-	"En Preparación": {"Enviado", "Rechazado"},
-	"Enviado":        {"Entregado"},
-}
-
-var userTransitions = map[string][]string{
-	"Pendiente":      {"Cancelado"},
-	"En Preparación": {"Cancelado"},
+type OrderStatusService struct {
+	repo      OrderRepository
+	workflows WorkflowProvider
+	reasons   *ReasonCodeCatalog
+	policy    OrderPolicy
+	files     FileStore
+	hooks     []registeredHook
 }
 
-// Estados finales
-var finalStates = map[string]bool{
-	"Cancelado": true,
-	"Rechazado": true,
-	"Entregado": true,
+func NewOrderStatusService(r OrderRepository, w WorkflowProvider, reasons *ReasonCodeCatalog, files FileStore, policy OrderPolicy) *OrderStatusService {
+	return &OrderStatusService{repo: r, workflows: w, reasons: reasons, files: files, policy: policy}
 }
 
 // CreateStatus crea o hace upsert del estado inicial de la orden.
-// IMPORTANTE: fuerza el estado a "Pendiente" (siempre).
+// IMPORTANTE: fuerza el estado inicial del workflow ("Pendiente"), sin importar lo que llegue.
+// El workflow depende del tipo de entrega; si viene vacío se usa home_delivery.
 // Se puede invocar desde el consumer Rabbit (primario) o vía API para pruebas.
-// Si el shipping del request está vacío, se usa la dirección constante.
-func (s *OrderStatusService) InitOrderStatus(ctx context.Context, orderId string, userId string, shipping dto.ShippingDTO, fromRabbit bool) (*model.OrderStatus, error) {
+// Si el shipping del request está vacío, se usa la dirección constante (salvo en
+// el workflow digital, que no tiene envío y queda sin dirección).
+func (s *OrderStatusService) InitOrderStatus(ctx context.Context, orderId string, userId string, workflow string, shipping dto.ShippingDTO, articles []dto.ArticleDTO, fromRabbit bool) (*model.OrderStatus, error) {
 
 	// 1. Primero preguntamos si ya existe
 	existing, err := s.repo.FindByOrderID(ctx, orderId)
@@ -94,9 +106,17 @@ func (s *OrderStatusService) InitOrderStatus(ctx context.Context, orderId string
 	}
 
 	// 3. Si da error ErrNotFound, entonces sí la creamos desde cero
+	wf, err := s.workflows.Workflow(ctx, workflowName(workflow))
+	if err != nil {
+		return nil, err
+	}
+	initial, ok := wf.Initial()
+	if !ok {
+		return nil, ErrNoInitialState
+	}
 
 	// Shipping por defecto si viene vacío
-	if shipping.AddressLine1 == "" {
+	if shipping.AddressLine1 == "" && wf.Name != model.DigitalWorkflow {
 		shipping = dto.ShippingDTO{
 			AddressLine1: "Av San Martín 1234",
 			City:         "Mendoza",
@@ -110,13 +130,17 @@ func (s *OrderStatusService) InitOrderStatus(ctx context.Context, orderId string
 	status := &model.OrderStatus{
 		OrderID:   orderId,
 		UserID:    userId,
-		Status:    "Pendiente",
+		Workflow:  wf.Name,
+		Status:    initial,
 		Shipping:  dtoToModelShipping(shipping),
+		Items:     newLineItems(articles, userId),
+		Version:   1,
+		SLASince:  time.Now(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		History: []model.StatusRecord{
 			{
-				Status:    "Pendiente",
+				Status:    initial,
 				Current:   true,
 				Reason:    "Orden inicializada",
 				UserID:    userId,
@@ -133,24 +157,41 @@ func (s *OrderStatusService) GetByOrderID(ctx context.Context, orderID string) (
 	return s.repo.FindByOrderID(ctx, orderID)
 }
 
-func (s *OrderStatusService) GetAll(ctx context.Context) ([]*model.OrderStatus, error) {
-	return s.repo.FindAll(ctx)
+// GetOrderFor devuelve la orden si el actor puede verla: es el dueño, el repartidor
+// asignado o tiene un rol con lectura de todas las órdenes.
+func (s *OrderStatusService) GetOrderFor(ctx context.Context, orderID string, actor Actor) (*model.OrderStatus, error) {
+	o, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.UserID != actor.ID && !isAssignedCourier(o, actor) && !actor.CanReadAllOrders() {
+		return nil, ErrForbidden
+	}
+	return o, nil
 }
 
-func (s *OrderStatusService) GetByStatus(ctx context.Context, status string) ([]*model.OrderStatus, error) {
-	return s.repo.FindByStatus(ctx, status)
+// UpdateStatus valida y realiza la transición entre estados según las reglas de negocio.
+// Si el estado destino exige datos (ej: tracking para "Enviado"), se devuelve
+// *MissingFieldsError con los campos que faltan.
+// Las transiciones permitidas dependen de los roles del actor (y de si es el dueño
+// o el repartidor asignado).
+func (s *OrderStatusService) UpdateStatus(ctx context.Context, orderID string, req dto.UpdateStatusRequest, actor Actor) error {
+	return s.updateStatus(ctx, orderID, req, actor, false)
 }
 
-func (s *OrderStatusService) GetByUserID(ctx context.Context, userID string) ([]*model.OrderStatus, error) {
-	return s.repo.FindByUserID(ctx, userID)
-}
+// updateStatus hace todas las validaciones de UpdateStatus; con dryRun no escribe nada.
+func (s *OrderStatusService) updateStatus(ctx context.Context, orderID string, req dto.UpdateStatusRequest, actor Actor, dryRun bool) error {
+	newStatus := req.Status
 
-// UpdateStatus valida y realiza la transición entre estados según las reglas de negocio.
-func (s *OrderStatusService) UpdateStatus(ctx context.Context, orderID string, newStatus string, reason string, actorID string, isAdmin bool) error {
 	ord, err := s.repo.FindByOrderID(ctx, orderID)
 	if err != nil {
 		return err
 	}
+	// Con If-Match, la orden tiene que seguir en la versión que vio el cliente. Se revisa
+	// antes que nada: un If-Match viejo falla aunque la orden ya esté en el estado pedido.
+	if req.Version != nil && *req.Version != ord.Version {
+		return ErrStaleVersion
+	}
 
 	current := ord.Status
 
@@ -158,48 +199,129 @@ func (s *OrderStatusService) UpdateStatus(ctx context.Context, orderID string, n
 	if current == newStatus {
 		return nil
 	}
+
+	// Grafo vigente del workflow de la orden, según el catálogo de estados
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return err
+	}
+
 	// Si el estado actual es final, no se puede cambiar
-	if finalStates[current] {
+	if wf.IsFinal(current) {
 		return ErrFinalState
 	}
+	// Pausa y reanudación: "En Espera" no forma parte del grafo del workflow
+	if newStatus == OnHoldStatus || current == OnHoldStatus {
+		return s.holdOrResume(ctx, ord, req, actor, dryRun)
+	}
 	// Si el nuevo estado no es válido, error
-	if !isValidState(newStatus) {
+	if !wf.IsValidState(newStatus) {
 		return ErrInvalidTransition
 	}
 
-	// Determinamos si el actor es el dueño de la orden
-	isOwner := ord.UserID == actorID
-
-	// Puede realizar la transición si es admin?
-	allowedAsAdmin := isAdmin && contains(adminTransitions[current], newStatus)
-
-	// Puede realizar la transición si es dueño?
-	allowedAsOwner := isOwner && contains(userTransitions[current], newStatus)
+	// Determinamos si el actor es el dueño de la orden, o su repartidor
+	isOwner := ord.UserID == actor.ID
+	isCourier := isAssignedCourier(ord, actor)
 
 	// Tiene permiso para hacer cualquier cambio?
-	if !isAdmin && !isOwner {
-		return ErrForbidden // Ni es admin, ni es el dueño -> Fuera.
+	if len(actor.Roles) == 0 && !isOwner {
+		return ErrForbidden // Sin roles y no es el dueño -> Fuera.
 	}
 
-	if !allowedAsAdmin && !allowedAsOwner {
-		// Caso especial: Si es admin, pero no es el dueño, no puede cancelar
-		if isAdmin && newStatus == "Cancelado" && !isOwner {
+	// Puede realizar la transición con alguno de sus roles (o como dueño)?
+	// El rol de repartidor sólo vale sobre las órdenes que tiene asignadas.
+	roles := slices.DeleteFunc(slices.Clone(actor.Roles), func(r string) bool {
+		return r == RoleCourier && !isCourier
+	})
+	if isOwner {
+		roles = append(roles, RoleUser)
+	}
+	allowed := false
+	for _, role := range roles {
+		if wf.CanTransition(role, current, newStatus) {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		// Caso especial: transiciones que sólo puede hacer el dueño (ej: cancelar)
+		if !isOwner && wf.CanTransition(RoleUser, current, newStatus) {
+			return ErrForbidden
+		}
+		// Lo mismo para un repartidor con una orden que no le fue asignada
+		if actor.Has(RoleCourier) && !isCourier && wf.CanTransition(RoleCourier, current, newStatus) {
 			return ErrForbidden
 		}
 
 		return ErrInvalidTransition
 	}
 
+	// Datos exigidos por el estado destino
+	if missing := missingFields(wf.Requires(newStatus), req.Data); len(missing) > 0 {
+		return &MissingFieldsError{Status: newStatus, Fields: missing}
+	}
+	// Estado exigido a las líneas (ej: todo empaquetado antes de "Enviado")
+	if pending := pendingItems(ord.Items, wf.ItemsRequire(newStatus)); len(pending) > 0 {
+		return &ItemsNotReadyError{Status: newStatus, Articles: pending}
+	}
+	// Una orden despachada en varios paquetes se entrega cuando se entregan todos
+	if newStatus == ShipmentDelivered {
+		if pending := pendingShipments(ord.Shipments); len(pending) > 0 {
+			return &ShipmentsPendingError{Shipments: pending}
+		}
+	}
+	// El código de motivo, si viene, tiene que ser del catálogo y aplicar al estado destino
+	if req.Data.ReasonCode != "" {
+		if err := s.reasons.Check(req.Data.ReasonCode, newStatus); err != nil {
+			return err
+		}
+	}
+
 	// Actualización del estado
 	record := model.StatusRecord{
-		Status:    newStatus,
-		Reason:    reason,
-		UserID:    actorID,
-		Timestamp: time.Now(),
-		Current:   true,
+		Status:     newStatus,
+		Reason:     req.Reason,
+		ReasonCode: req.Data.ReasonCode,
+		UserID:     actor.ID,
+		Timestamp:  time.Now(),
+		Data:       dtoToModelTransitionData(req.Data),
+		Current:    true,
+	}
+
+	if dryRun {
+		return nil
+	}
+	return s.applyTransition(ctx, ord, record, record.Timestamp)
+}
+
+// applyTransition guarda el nuevo registro (con el inicio del SLA en la misma
+// escritura) y, si salió bien, ejecuta los hooks.
+func (s *OrderStatusService) applyTransition(ctx context.Context, ord *model.OrderStatus, record model.StatusRecord, slaSince time.Time) error {
+	from := ord.Status
+	record.SLASince = slaSince
+	if err := s.repo.UpdateStatus(ctx, ord.OrderID, ord.Version, record.Status, slaSince, record); err != nil {
+		return err
 	}
 
-	return s.repo.UpdateStatus(ctx, orderID, newStatus, record)
+	// Reacciones al cambio (notificaciones, eventos, etc.)
+	for i := range ord.History {
+		ord.History[i].Current = false
+	}
+	ord.Status = record.Status
+	ord.Version++
+	ord.History = append(ord.History, record)
+	ord.UpdatedAt = record.Timestamp
+	ord.SLASince = slaSince
+
+	s.runHooks(ctx, TransitionEvent{
+		Order:   ord,
+		From:    from,
+		To:      record.Status,
+		ActorID: record.UserID,
+		Record:  record,
+	})
+	return nil
 }
 
 func contains(arr []string, s string) bool {
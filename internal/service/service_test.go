@@ -0,0 +1,155 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+	"order-status-service-2/internal/repository"
+	"order-status-service-2/internal/service"
+)
+
+var (
+	admin = service.Actor{ID: "admin-1", Roles: []string{service.RoleAdmin}}
+	owner = service.Actor{ID: "user-1"}
+)
+
+// newService arma OrderStatusService con los repositorios en memoria y el catálogo
+// sembrado desde el workflow.yaml del repo.
+func newService(t *testing.T) (*service.OrderStatusService, *repository.MemoryOrderRepository) {
+	t.Helper()
+	return newServiceWithFiles(t, repository.NewMemoryFileStore())
+}
+
+// newServiceWithFiles es newService con otro almacenamiento para las constancias
+func newServiceWithFiles(t *testing.T, files service.FileStore) (*service.OrderStatusService, *repository.MemoryOrderRepository) {
+	t.Helper()
+	file, err := service.LoadWorkflowFile("../../workflow.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := repository.NewMemoryOrderRepository()
+	catalog := service.NewStateCatalogService(repository.NewMemoryStateCatalogRepository(), orders, repository.NewMemoryScheduleRepository())
+	if err := catalog.Seed(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewOrderStatusService(orders, catalog, service.NewReasonCodeCatalog(file.ReasonCodes), files, service.OrderPolicy{
+		RevertWindow:        time.Hour,
+		ReturnWindow:        24 * time.Hour,
+		ProofMaxFileSize:    1 << 20,
+		BulkConcurrency:     1,
+		MaxDeliveryAttempts: 3,
+	})
+	return svc, orders
+}
+
+func initOrder(t *testing.T, svc *service.OrderStatusService, orderID string) *model.OrderStatus {
+	t.Helper()
+	o, err := svc.InitOrderStatus(context.Background(), orderID, owner.ID, "", dto.ShippingDTO{}, nil, false)
+	if err != nil {
+		t.Fatalf("InitOrderStatus: %v", err)
+	}
+	return o
+}
+
+func TestUpdateStatus(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	initOrder(t, svc, "ORD-1")
+
+	if err := svc.UpdateStatus(ctx, "ORD-1", dto.UpdateStatusRequest{Status: "En Preparación"}, admin); err != nil {
+		t.Fatalf("Pendiente → En Preparación: %v", err)
+	}
+
+	// El dueño sólo puede cancelar
+	err := svc.UpdateStatus(ctx, "ORD-1", dto.UpdateStatusRequest{Status: "Enviado"}, owner)
+	if !errors.Is(err, service.ErrInvalidTransition) {
+		t.Fatalf("el dueño pasó a Enviado: se esperaba ErrInvalidTransition, se obtuvo %v", err)
+	}
+
+	// "Enviado" exige transportista y número de seguimiento
+	var missing *service.MissingFieldsError
+	err = svc.UpdateStatus(ctx, "ORD-1", dto.UpdateStatusRequest{Status: "Enviado"}, admin)
+	if !errors.As(err, &missing) || len(missing.Fields) != 2 {
+		t.Fatalf("se esperaba MissingFieldsError con 2 campos, se obtuvo %v", err)
+	}
+
+	err = svc.UpdateStatus(ctx, "ORD-1", dto.UpdateStatusRequest{
+		Status: "Enviado",
+		Data:   dto.TransitionDataDTO{Carrier: "Andreani", TrackingNumber: "AR123"},
+	}, admin)
+	if err != nil {
+		t.Fatalf("En Preparación → Enviado: %v", err)
+	}
+
+	got, err := svc.GetByOrderID(ctx, "ORD-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "Enviado" || got.Version != 3 || len(got.History) != 3 {
+		t.Fatalf("se esperaba Enviado, versión 3 y 3 registros; se obtuvo %q, %d y %d", got.Status, got.Version, len(got.History))
+	}
+	for i, h := range got.History {
+		if h.Current != (i == len(got.History)-1) {
+			t.Fatalf("registro %d (%q): current = %t", i, h.Status, h.Current)
+		}
+	}
+}
+
+func TestUpdateStatusStaleIfMatch(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	o := initOrder(t, svc, "ORD-2")
+	seen := o.Version
+
+	if err := svc.UpdateStatus(ctx, "ORD-2", dto.UpdateStatusRequest{Status: "En Preparación"}, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	// Aunque la orden ya esté en el estado pedido, un If-Match viejo falla
+	err := svc.UpdateStatus(ctx, "ORD-2", dto.UpdateStatusRequest{Status: "En Preparación", Version: &seen}, admin)
+	if !errors.Is(err, service.ErrStaleVersion) {
+		t.Fatalf("se esperaba ErrStaleVersion, se obtuvo %v", err)
+	}
+}
+
+func TestHoldAndResumeKeepSLA(t *testing.T) {
+	ctx := context.Background()
+	svc, orders := newService(t)
+	initOrder(t, svc, "ORD-3")
+	before, err := orders.FindByOrderID(ctx, "ORD-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.UpdateStatus(ctx, "ORD-3", dto.UpdateStatusRequest{Status: service.OnHoldStatus, Reason: "Revisión de pago"}, admin); err != nil {
+		t.Fatalf("poner en espera: %v", err)
+	}
+	held, err := orders.FindByOrderID(ctx, "ORD-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held.SLASince.Equal(before.SLASince) || held.Version != before.Version+1 {
+		t.Fatalf("en espera: sla_since %s (antes %s), versión %d", held.SLASince, before.SLASince, held.Version)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := svc.ResumeOrder(ctx, "ORD-3", "Pago verificado", admin); err != nil {
+		t.Fatalf("reanudar: %v", err)
+	}
+	resumed, err := orders.FindByOrderID(ctx, "ORD-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Status != "Pendiente" || resumed.Version != before.Version+2 {
+		t.Fatalf("reanudada: %q, versión %d", resumed.Status, resumed.Version)
+	}
+	// El plazo se corre lo que duró la pausa
+	if !resumed.SLASince.After(before.SLASince) {
+		t.Fatalf("sla_since no se corrió: %s (antes %s)", resumed.SLASince, before.SLASince)
+	}
+}
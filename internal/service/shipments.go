@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Estados de cada envío (paquete). Al entregarse el último, la orden pasa a "Entregado".
+const (
+	ShipmentShipped   = "Enviado"
+	ShipmentDelivered = "Entregado"
+)
+
+var (
+	ErrShipmentNotFound          = errors.New("la orden no tiene ese envío")
+	ErrInvalidShipmentTransition = errors.New("transición de estado inválida para el envío")
+	ErrArticleNotPacked          = errors.New("sólo se pueden despachar artículos empaquetados")
+	ErrShipmentOrderStatus       = fmt.Errorf("sólo se despachan y entregan paquetes de órdenes en %q", ShipmentShipped)
+	ErrOrderNotDelivered         = fmt.Errorf("el envío quedó entregado, pero la orden no pudo pasar a %q", ShipmentDelivered)
+)
+
+// ShipmentsPendingError se devuelve al intentar pasar la orden a "Entregado"
+// mientras alguno de sus envíos no fue entregado.
+type ShipmentsPendingError struct {
+	Shipments []string
+}
+
+func (e *ShipmentsPendingError) Error() string {
+	return fmt.Sprintf("hay envíos sin entregar: %s", strings.Join(e.Shipments, ", "))
+}
+
+// pendingShipments devuelve los envíos que todavía no fueron entregados
+func pendingShipments(shipments []model.Shipment) []string {
+	var pending []string
+	for _, sh := range shipments {
+		if sh.Status != ShipmentDelivered {
+			pending = append(pending, sh.ShipmentID)
+		}
+	}
+	return pending
+}
+
+// newID genera un identificador corto para envíos y devoluciones
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CreateShipment despacha un paquete con parte (o todos) los artículos de la orden.
+// La orden tiene que estar en "Enviado": si los paquetes se entregaran antes, sus
+// artículos ya no cumplirían el itemsRequire de "Enviado" y la orden quedaría trabada.
+// Los artículos tienen que estar empaquetados y pasan a "Enviado" junto con el envío.
+func (s *OrderStatusService) CreateShipment(ctx context.Context, orderID string, req dto.CreateShipmentRequest, actorID string) (*model.Shipment, error) {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if ord.Status != ShipmentShipped {
+		return nil, ErrShipmentOrderStatus
+	}
+
+	status := map[string]string{}
+	for _, it := range ord.Items {
+		status[it.ArticleID] = it.Status
+	}
+
+	var articles []string
+	for _, id := range req.Articles {
+		if contains(articles, id) {
+			continue
+		}
+		st, ok := status[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrItemNotFound, id)
+		}
+		if st != ItemPacked {
+			return nil, fmt.Errorf("%w: %s está en %q", ErrArticleNotPacked, id, st)
+		}
+		articles = append(articles, id)
+	}
+
+	now := time.Now()
+	record := model.StatusRecord{
+		Status:    ShipmentShipped,
+		Reason:    req.Reason,
+		UserID:    actorID,
+		Timestamp: now,
+		Data: &model.TransitionData{
+			Carrier:        req.Carrier,
+			TrackingNumber: req.TrackingNumber,
+		},
+	}
+	shipment := model.Shipment{
+		ShipmentID:     newID(),
+		Articles:       articles,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Status:         ShipmentShipped,
+		History:        []model.StatusRecord{record},
+		CreatedAt:      now,
+	}
+
+	if err := s.repo.AddShipment(ctx, orderID, ord.Version, shipment, ItemShipped, record); err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// UpdateShipmentStatus marca un envío (y sus artículos) como entregado; la orden tiene
+// que estar en "Enviado" (no en espera). Si con eso quedan entregados todos los envíos
+// y todos los artículos, la orden pasa a "Entregado" como actor "system", siempre que
+// el workflow lo permita; si ese paso falla se devuelve ErrOrderNotDelivered (o el
+// conflicto de versión) y repetir el pedido lo vuelve a intentar.
+// Un repartidor sólo puede entregar envíos de órdenes asignadas a él.
+func (s *OrderStatusService) UpdateShipmentStatus(ctx context.Context, orderID, shipmentID string, req dto.UpdateShipmentStatusRequest, actor Actor) error {
+	ord, err := s.repo.FindByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if !actor.HasAny(RoleAdmin, RoleWarehouse) && !isAssignedCourier(ord, actor) {
+		return ErrForbidden
+	}
+	if ord.Status != ShipmentShipped {
+		return ErrShipmentOrderStatus
+	}
+
+	wf, err := s.workflows.Workflow(ctx, workflowName(ord.Workflow))
+	if err != nil {
+		return err
+	}
+
+	var shipment *model.Shipment
+	for i := range ord.Shipments {
+		if ord.Shipments[i].ShipmentID == shipmentID {
+			shipment = &ord.Shipments[i]
+			break
+		}
+	}
+	if shipment == nil {
+		return ErrShipmentNotFound
+	}
+
+	if shipment.Status == req.Status {
+		// Ya estaba entregado: por si el pedido anterior no llegó a pasar la orden
+		return s.deliverIfComplete(ctx, ord, wf)
+	}
+	if shipment.Status != ShipmentShipped || req.Status != ShipmentDelivered {
+		return ErrInvalidShipmentTransition
+	}
+
+	record := model.StatusRecord{
+		Status:    req.Status,
+		Reason:    req.Reason,
+		UserID:    actor.ID,
+		Timestamp: time.Now(),
+	}
+	if err := s.repo.UpdateShipmentStatus(ctx, orderID, shipmentID, shipment.Articles, shipment.Status, req.Status, record); err != nil {
+		return err
+	}
+
+	// Reflejamos el cambio en la copia leída para decidir si la orden quedó entregada
+	shipment.Status = req.Status
+	for i := range ord.Items {
+		if contains(shipment.Articles, ord.Items[i].ArticleID) {
+			ord.Items[i].Status = req.Status
+		}
+	}
+	return s.deliverIfComplete(ctx, ord, wf)
+}
+
+// deliverIfComplete pasa la orden a "Entregado" si todos sus envíos y artículos
+// fueron entregados y el workflow lo permite.
+func (s *OrderStatusService) deliverIfComplete(ctx context.Context, ord *model.OrderStatus, wf *Workflow) error {
+	if len(pendingShipments(ord.Shipments)) > 0 || len(pendingItems(ord.Items, []string{ItemDelivered})) > 0 {
+		return nil
+	}
+	if !wf.CanTransition(RoleAdmin, ord.Status, ShipmentDelivered) {
+		return nil
+	}
+
+	err := s.UpdateStatus(ctx, ord.OrderID, dto.UpdateStatusRequest{
+		Status: ShipmentDelivered,
+		Reason: "Todos los envíos fueron entregados",
+	}, SystemActor)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOrderNotDelivered, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/service"
+)
+
+// shippedOrder deja una orden en "Enviado" con A-1 y A-2 empaquetados y A-3 cancelado
+func shippedOrder(t *testing.T, svc *service.OrderStatusService, orderID string) {
+	t.Helper()
+	initOrderWithItems(t, svc, orderID,
+		dto.ArticleDTO{ArticleID: "A-1", Quantity: 1},
+		dto.ArticleDTO{ArticleID: "A-2", Quantity: 1},
+		dto.ArticleDTO{ArticleID: "A-3", Quantity: 1})
+	setItems(t, svc, orderID, map[string][]string{
+		"A-1": {service.ItemPacked},
+		"A-2": {service.ItemPacked},
+		"A-3": {service.ItemCancelled},
+	})
+	moveTo(t, svc, orderID,
+		dto.UpdateStatusRequest{Status: "En Preparación"},
+		dto.UpdateStatusRequest{Status: "Enviado", Data: shippedData})
+}
+
+func shipmentRequest(articles ...string) dto.CreateShipmentRequest {
+	return dto.CreateShipmentRequest{Articles: articles, Carrier: "Andreani", TrackingNumber: "AR123"}
+}
+
+func TestCreateShipment(t *testing.T) {
+	tests := []struct {
+		name         string
+		notShipped   bool // la orden queda en "En Preparación"
+		articles     []string
+		wantErr      error
+		wantArticles []string
+	}{
+		{name: "un artículo", articles: []string{"A-1"}, wantArticles: []string{"A-1"}},
+		{name: "repetidos se despachan una vez", articles: []string{"A-1", "A-2", "A-1"}, wantArticles: []string{"A-1", "A-2"}},
+		{name: "artículo cancelado", articles: []string{"A-1", "A-3"}, wantErr: service.ErrArticleNotPacked},
+		{name: "artículo inexistente", articles: []string{"Z-9"}, wantErr: service.ErrItemNotFound},
+		{name: "orden sin despachar", notShipped: true, articles: []string{"A-1"}, wantErr: service.ErrShipmentOrderStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			if tt.notShipped {
+				initOrderWithItems(t, svc, "ORD-SHIP", dto.ArticleDTO{ArticleID: "A-1", Quantity: 1})
+				setItems(t, svc, "ORD-SHIP", map[string][]string{"A-1": {service.ItemPacked}})
+				moveTo(t, svc, "ORD-SHIP", dto.UpdateStatusRequest{Status: "En Preparación"})
+			} else {
+				shippedOrder(t, svc, "ORD-SHIP")
+			}
+
+			shipment, err := svc.CreateShipment(ctx, "ORD-SHIP", shipmentRequest(tt.articles...), admin.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			ord, ferr := orders.FindByOrderID(ctx, "ORD-SHIP")
+			if ferr != nil {
+				t.Fatal(ferr)
+			}
+			if tt.wantErr != nil {
+				if len(ord.Shipments) != 0 {
+					t.Fatalf("el envío rechazado quedó guardado: %+v", ord.Shipments)
+				}
+				return
+			}
+
+			// Los artículos del envío pasan a "Enviado" con él
+			if !slices.Equal(shipment.Articles, tt.wantArticles) || len(ord.Shipments) != 1 {
+				t.Fatalf("se esperaba un envío con %v, se obtuvo %v (%d envíos)", tt.wantArticles, shipment.Articles, len(ord.Shipments))
+			}
+			for _, it := range ord.Items {
+				if slices.Contains(tt.wantArticles, it.ArticleID) && it.Status != service.ItemShipped {
+					t.Fatalf("%s: se esperaba %q, se obtuvo %q", it.ArticleID, service.ItemShipped, it.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestShipmentRollup(t *testing.T) {
+	tests := []struct {
+		name       string
+		deliver    []int // envíos que se entregan, en orden
+		actor      service.Actor
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "uno de dos entregado", deliver: []int{0}, wantStatus: "Enviado"},
+		{name: "todos entregados", deliver: []int{0, 1}, wantStatus: "Entregado"},
+		{name: "en otro orden", deliver: []int{1, 0}, wantStatus: "Entregado"},
+		{name: "el dueño no entrega", deliver: []int{0}, actor: owner, wantErr: service.ErrForbidden, wantStatus: "Enviado"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, orders := newService(t)
+			shippedOrder(t, svc, "ORD-SHIP")
+			var ids []string
+			for _, article := range []string{"A-1", "A-2"} {
+				sh, err := svc.CreateShipment(ctx, "ORD-SHIP", shipmentRequest(article), admin.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, sh.ShipmentID)
+			}
+			actor := admin
+			if tt.actor.ID != "" {
+				actor = tt.actor
+			}
+
+			var err error
+			for _, i := range tt.deliver {
+				err = svc.UpdateShipmentStatus(ctx, "ORD-SHIP", ids[i], dto.UpdateShipmentStatusRequest{Status: service.ShipmentDelivered}, actor)
+				if err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("se esperaba %v, se obtuvo %v", tt.wantErr, err)
+			}
+			ord, err := orders.FindByOrderID(ctx, "ORD-SHIP")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ord.Status != tt.wantStatus {
+				t.Fatalf("se esperaba la orden en %q, se obtuvo %q", tt.wantStatus, ord.Status)
+			}
+		})
+	}
+}
+
+func TestDeliverWithPendingShipments(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService(t)
+	shippedOrder(t, svc, "ORD-SHIP")
+	sh, err := svc.CreateShipment(ctx, "ORD-SHIP", shipmentRequest("A-1", "A-2"), admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pending *service.ShipmentsPendingError
+	err = svc.UpdateStatus(ctx, "ORD-SHIP", dto.UpdateStatusRequest{Status: "Entregado"}, admin)
+	if !errors.As(err, &pending) || !slices.Equal(pending.Shipments, []string{sh.ShipmentID}) {
+		t.Fatalf("se esperaba ShipmentsPendingError con %s, se obtuvo %v", sh.ShipmentID, err)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// Acciones posibles de una regla de SLA (StateTimeout.Action)
+const (
+	TimeoutTransition = "transition"
+	TimeoutFlag       = "flag"
+)
+
+// Actor con el que quedan registradas en el historial las transiciones automáticas
+const SystemActorID = "system"
+
+// Códigos de las alertas del scheduler: SLA vencido (regla flag) y transición
+// automática que no se puede aplicar a la orden (regla transition)
+const (
+	FlagSLAExpired          = "SLA_EXPIRED"
+	FlagSLATransitionFailed = "SLA_TRANSITION_FAILED"
+)
+
+const (
+	slaLockName  = "sla_scheduler"
+	slaBatchSize = 500
+)
+
+// Consultas que necesita el scheduler sobre order_statuses
+type StaleOrderRepository interface {
+	// FindStale devuelve órdenes del workflow en el estado indicado sin cambios desde q.Before,
+	// omitiendo las que ya tienen la alerta q.ExcludeFlag para ese estado.
+	FindStale(ctx context.Context, q model.StaleQuery) ([]*model.OrderStatus, error)
+	// AddFlag agrega la alerta si la orden no la tiene ya para ese estado
+	AddFlag(ctx context.Context, orderID string, flag model.OrderFlag) error
+}
+
+// Lock distribuido para que una sola réplica ejecute el scheduler a la vez
+type LockRepository interface {
+	Acquire(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+}
+
+// Fuente de las reglas de SLA (lo implementa StateCatalogService)
+type StateLister interface {
+	GetStates(ctx context.Context, workflow string) ([]*model.CatalogState, error)
+}
+
+// SLAScheduler recorre periódicamente las órdenes estancadas y aplica las reglas
+// de timeout de cada estado: transición automática o alerta.
+type SLAScheduler struct {
+	orders   *OrderStatusService
+	repo     StaleOrderRepository
+	states   StateLister
+	locks    LockRepository
+	interval time.Duration
+	owner    string
+}
+
+func NewSLAScheduler(orders *OrderStatusService, repo StaleOrderRepository, states StateLister, locks LockRepository, interval time.Duration) *SLAScheduler {
+	host, _ := os.Hostname()
+	return &SLAScheduler{
+		orders:   orders,
+		repo:     repo,
+		states:   states,
+		locks:    locks,
+		interval: interval,
+		owner:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Start ejecuta el scheduler en segundo plano hasta que se cancele ctx.
+func (s *SLAScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RunOnce(ctx); err != nil {
+					log.Println("❌ SLA scheduler:", err)
+				}
+			}
+		}
+	}()
+	log.Printf("⏱ SLA scheduler iniciado (cada %s)", s.interval)
+}
+
+// RunOnce aplica las reglas una vez, sólo si esta réplica tiene el lock.
+func (s *SLAScheduler) RunOnce(ctx context.Context) error {
+	ok, err := s.locks.Acquire(ctx, slaLockName, s.owner, 2*s.interval)
+	if err != nil || !ok {
+		return err
+	}
+
+	states, err := s.states.GetStates(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for _, st := range states {
+		if st.Timeout == nil {
+			continue
+		}
+		if err := s.applyRule(ctx, st); err != nil {
+			log.Printf("❌ SLA scheduler: estado %q (%s): %v", st.Name, workflowName(st.Workflow), err)
+		}
+	}
+	return nil
+}
+
+func (s *SLAScheduler) applyRule(ctx context.Context, st *model.CatalogState) error {
+	rule := st.Timeout
+	after, err := time.ParseDuration(rule.After)
+	if err != nil {
+		return err
+	}
+
+	// Una transición cuenta desde que la orden entró al estado (sla_since); una alerta,
+	// desde la última novedad de la orden (updated_at). Las órdenes que ya tienen la
+	// alerta de la regla se omiten, así no ocupan lugar en el lote en cada vuelta.
+	q := model.StaleQuery{
+		Workflow:    workflowName(st.Workflow),
+		Status:      st.Name,
+		Before:      time.Now().UTC().Add(-after),
+		ExcludeFlag: FlagSLATransitionFailed,
+		Limit:       slaBatchSize,
+	}
+	if rule.Action == TimeoutFlag {
+		q.ByUpdate, q.ExcludeFlag = true, FlagSLAExpired
+	}
+
+	orders, err := s.repo.FindStale(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	reason := rule.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("Sin cambios en %q por más de %s", st.Name, rule.After)
+	}
+
+	for _, ord := range orders {
+		switch rule.Action {
+		case TimeoutTransition:
+			err = s.orders.UpdateStatus(ctx, ord.OrderID, dto.UpdateStatusRequest{
+				Status: rule.To,
+				Reason: reason,
+				Data:   dto.TransitionDataDTO{ReasonCode: rule.ReasonCode},
+			}, SystemActor)
+		case TimeoutFlag:
+			err = s.repo.AddFlag(ctx, ord.OrderID, model.OrderFlag{
+				Code:      FlagSLAExpired,
+				Status:    st.Name,
+				Reason:    reason,
+				Timestamp: time.Now().UTC(),
+			})
+		default:
+			return fmt.Errorf("acción desconocida %q", rule.Action)
+		}
+
+		// Otra réplica o un usuario pudo haber movido la orden mientras tanto
+		if errors.Is(err, ErrFinalState) || errors.Is(err, ErrInvalidTransition) {
+			continue
+		}
+		if permanentRuleError(err) {
+			s.flagFailed(ctx, ord, st.Name, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("❌ SLA scheduler: orden %s: %v", ord.OrderID, err)
+			continue
+		}
+		log.Printf("⏱ SLA vencido en orden %s (%s): %s", ord.OrderID, st.Name, rule.Action)
+	}
+	return nil
+}
+
+// permanentRuleError indica que la transición de la regla no se puede aplicar a la
+// orden tal como está (faltan datos, artículos o envíos sin terminar, código de motivo
+// que ya no es válido): reintentarla en cada vuelta daría siempre el mismo error.
+func permanentRuleError(err error) bool {
+	var missing *MissingFieldsError
+	var notReady *ItemsNotReadyError
+	var shipmentsPending *ShipmentsPendingError
+	return errors.As(err, &missing) || errors.As(err, &notReady) || errors.As(err, &shipmentsPending) ||
+		errors.Is(err, ErrInvalidReasonCode) || errors.Is(err, ErrForbidden)
+}
+
+// flagFailed deja en la orden la alerta SLA_TRANSITION_FAILED con el motivo, para que
+// alguien la resuelva a mano; FindStale ya no la devuelve para ese estado.
+func (s *SLAScheduler) flagFailed(ctx context.Context, ord *model.OrderStatus, status string, cause error) {
+	err := s.repo.AddFlag(ctx, ord.OrderID, model.OrderFlag{
+		Code:      FlagSLATransitionFailed,
+		Status:    status,
+		Reason:    cause.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("❌ SLA scheduler: orden %s: %v", ord.OrderID, err)
+		return
+	}
+	log.Printf("⚠ SLA scheduler: no se puede aplicar la regla de %q a la orden %s, queda con alerta: %v", status, ord.OrderID, cause)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"slices"
+
+	"order-status-service-2/internal/model"
+)
+
+// Roles que pueden figurar en las transiciones del catálogo (ver actor.go)
+const (
+	RoleAdmin     = "admin"
+	RoleUser      = "user" // dueño de la orden
+	RoleWarehouse = "warehouse"
+	RoleCourier   = "courier"
+	RoleSupport   = "support"
+)
+
+var knownRoles = []string{RoleAdmin, RoleUser, RoleWarehouse, RoleCourier, RoleSupport}
+
+func isKnownRole(role string) bool {
+	return slices.Contains(knownRoles, role)
+}
+
+// Workflow es el grafo de estados vigente de un tipo de entrega,
+// armado a partir del catálogo.
+type Workflow struct {
+	Name   string
+	states map[string]*model.CatalogState
+}
+
+// NewWorkflow arma el grafo con los estados del catálogo que pertenecen al workflow indicado
+func NewWorkflow(name string, states []*model.CatalogState) *Workflow {
+	w := &Workflow{Name: name, states: map[string]*model.CatalogState{}}
+	for _, st := range states {
+		if workflowName(st.Workflow) == name {
+			w.states[st.Name] = st
+		}
+	}
+	return w
+}
+
+// workflowName normaliza el nombre del workflow (vacío = workflow por defecto)
+func workflowName(name string) string {
+	if name == "" {
+		return model.DefaultWorkflow
+	}
+	return name
+}
+
+func (w *Workflow) IsEmpty() bool {
+	return len(w.states) == 0
+}
+
+func (w *Workflow) IsValidState(s string) bool {
+	_, ok := w.states[s]
+	return ok
+}
+
+// Initial devuelve el estado con el que se inicializan las órdenes
+func (w *Workflow) Initial() (string, bool) {
+	for name, st := range w.states {
+		if st.Initial {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (w *Workflow) IsFinal(s string) bool {
+	st, ok := w.states[s]
+	return ok && st.Final
+}
+
+// NotifiesCustomer indica si al entrar al estado se notifica al cliente
+func (w *Workflow) NotifiesCustomer(s string) bool {
+	st, ok := w.states[s]
+	return ok && st.NotifiesCustomer
+}
+
+// IsReturnable indica si desde el estado el cliente puede pedir una devolución
+func (w *Workflow) IsReturnable(s string) bool {
+	st, ok := w.states[s]
+	return ok && st.Returnable
+}
+
+// Requires devuelve los datos exigidos para entrar al estado
+func (w *Workflow) Requires(s string) []string {
+	if st, ok := w.states[s]; ok {
+		return st.Requires
+	}
+	return nil
+}
+
+// ItemsRequire devuelve los estados en los que deben estar las líneas para entrar al estado
+func (w *Workflow) ItemsRequire(s string) []string {
+	if st, ok := w.states[s]; ok {
+		return st.ItemsRequire
+	}
+	return nil
+}
+
+// CanTransition indica si el rol puede pasar la orden de "from" a "to"
+func (w *Workflow) CanTransition(role, from, to string) bool {
+	st, ok := w.states[from]
+	if !ok {
+		return false
+	}
+	return contains(st.Transitions[role], to)
+}
+
+// reachable devuelve los estados alcanzables desde el inicial (con las transiciones de todos los roles)
+func (w *Workflow) reachable() map[string]bool {
+	initial, ok := w.Initial()
+	if !ok {
+		return map[string]bool{}
+	}
+	reached := map[string]bool{initial: true}
+	queue := []string{initial}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		st, ok := w.states[cur]
+		if !ok {
+			continue
+		}
+		for _, targets := range st.Transitions {
+			for _, next := range targets {
+				if !reached[next] {
+					reached[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return reached
+}
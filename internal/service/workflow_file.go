@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"order-status-service-2/internal/dto"
+	"order-status-service-2/internal/model"
+)
+
+// WorkflowFile es el conjunto de workflows versionado en git (ver workflow.yaml).
+type WorkflowFile struct {
+	Workflows   []WorkflowDefinition `yaml:"workflows" json:"workflows"`
+	ReasonCodes []model.ReasonCode   `yaml:"reasonCodes" json:"reasonCodes"`
+}
+
+// WorkflowDefinition describe el recorrido de un tipo de entrega.
+type WorkflowDefinition struct {
+	Name    string            `yaml:"name" json:"name"`
+	Initial string            `yaml:"initial" json:"initial"`
+	States  []StateDefinition `yaml:"states" json:"states"`
+}
+
+type StateDefinition struct {
+	Name             string              `yaml:"name" json:"name"`
+	Final            bool                `yaml:"final" json:"final"`
+	NotifiesCustomer bool                `yaml:"notifiesCustomer" json:"notifiesCustomer"` // al entrar se notifica al cliente
+	Returnable       bool                `yaml:"returnable" json:"returnable"`             // el cliente puede pedir una devolución
+	Requires         []string            `yaml:"requires" json:"requires"`                 // datos exigidos para entrar al estado
+	ItemsRequire     []string            `yaml:"itemsRequire" json:"itemsRequire"`         // estados de línea exigidos
+	Timeout          *model.StateTimeout `yaml:"timeout" json:"timeout"`                   // regla de SLA
+	Transitions      map[string][]string `yaml:"transitions" json:"transitions"`           // rol -> estados destino
+}
+
+// LoadWorkflowFile lee el archivo (YAML o JSON según la extensión) y lo valida.
+func LoadWorkflowFile(path string) (*WorkflowFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el workflow %s: %w", path, err)
+	}
+
+	var file WorkflowFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s mal formado: %w", path, err)
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("workflow %s inválido: %w", path, err)
+	}
+	return &file, nil
+}
+
+// Validate valida cada workflow, exige que exista el workflow por defecto
+// y revisa el catálogo de motivos.
+func (f *WorkflowFile) Validate() error {
+	if len(f.Workflows) == 0 {
+		return errors.New("el archivo no define workflows")
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for i := range f.Workflows {
+		d := &f.Workflows[i]
+		if strings.TrimSpace(d.Name) == "" {
+			errs = append(errs, errors.New("hay un workflow sin nombre"))
+			continue
+		}
+		if seen[d.Name] {
+			errs = append(errs, fmt.Errorf("workflow %q duplicado", d.Name))
+		}
+		seen[d.Name] = true
+
+		if err := d.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("workflow %q: %w", d.Name, err))
+		}
+	}
+
+	if !seen[model.DefaultWorkflow] {
+		errs = append(errs, fmt.Errorf("falta el workflow por defecto %q", model.DefaultWorkflow))
+	}
+	if err := f.validateReasonCodes(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CatalogStates convierte todos los workflows al formato de state_catalog
+func (f *WorkflowFile) CatalogStates() []*model.CatalogState {
+	var out []*model.CatalogState
+	for i := range f.Workflows {
+		out = append(out, f.Workflows[i].CatalogStates()...)
+	}
+	return out
+}
+
+// Validate devuelve todos los problemas encontrados en la definición:
+// estados duplicados o inexistentes, roles o datos requeridos desconocidos, estados finales
+// con transiciones de salida y estados inalcanzables desde el inicial.
+func (d *WorkflowDefinition) Validate() error {
+	var errs []error
+
+	if len(d.States) == 0 {
+		return errors.New("el workflow no define estados")
+	}
+
+	names := make(map[string]bool, len(d.States))
+	for _, st := range d.States {
+		if strings.TrimSpace(st.Name) == "" {
+			errs = append(errs, errors.New("hay un estado sin nombre"))
+			continue
+		}
+		if names[st.Name] {
+			errs = append(errs, fmt.Errorf("estado %q duplicado", st.Name))
+		}
+		if st.Name == OnHoldStatus {
+			errs = append(errs, fmt.Errorf("%q es un estado reservado", OnHoldStatus))
+		}
+		names[st.Name] = true
+	}
+
+	if !names[d.Initial] {
+		errs = append(errs, fmt.Errorf("el estado inicial %q no existe", d.Initial))
+	}
+
+	for _, st := range d.States {
+		for _, f := range st.Requires {
+			if !isKnownField(f) {
+				errs = append(errs, fmt.Errorf("estado %q: dato requerido desconocido %q", st.Name, f))
+			}
+		}
+		for _, is := range st.ItemsRequire {
+			if !isItemStatus(is) {
+				errs = append(errs, fmt.Errorf("estado %q: estado de artículo desconocido %q", st.Name, is))
+			}
+		}
+		if st.Returnable && !st.Final {
+			errs = append(errs, fmt.Errorf("estado %q: sólo un estado final puede admitir devoluciones", st.Name))
+		}
+		for role, targets := range st.Transitions {
+			if !isKnownRole(role) {
+				errs = append(errs, fmt.Errorf("estado %q: rol desconocido %q", st.Name, role))
+			}
+			if st.Final && len(targets) > 0 {
+				errs = append(errs, fmt.Errorf("estado final %q tiene transiciones de salida", st.Name))
+			}
+			for _, to := range targets {
+				if !names[to] {
+					errs = append(errs, fmt.Errorf("estado %q: transición hacia estado inexistente %q", st.Name, to))
+				}
+			}
+		}
+	}
+
+	for _, st := range d.States {
+		if st.Timeout != nil {
+			if err := d.validateTimeout(st); err != nil {
+				errs = append(errs, fmt.Errorf("estado %q: %w", st.Name, err))
+			}
+		}
+	}
+
+	if names[d.Initial] {
+		reached := d.reachableFrom(d.Initial)
+		for _, st := range d.States {
+			if st.Name != "" && !reached[st.Name] {
+				errs = append(errs, fmt.Errorf("estado %q inalcanzable desde %q", st.Name, d.Initial))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTimeout verifica que la regla de SLA se pueda aplicar como actor "system"
+// (con las transiciones de admin) y que cubra los datos que exige el estado destino.
+func (d *WorkflowDefinition) validateTimeout(st StateDefinition) error {
+	t := st.Timeout
+	after, err := time.ParseDuration(t.After)
+	if err != nil || after <= 0 {
+		return fmt.Errorf("timeout: duración inválida %q", t.After)
+	}
+	if st.Final {
+		return errors.New("timeout: un estado final no puede vencer")
+	}
+
+	switch t.Action {
+	case TimeoutFlag:
+		return nil
+	case TimeoutTransition:
+		if !contains(st.Transitions[RoleAdmin], t.To) {
+			return fmt.Errorf("timeout: no hay transición de admin hacia %q", t.To)
+		}
+		for _, target := range d.States {
+			if target.Name != t.To {
+				continue
+			}
+			missing := missingFields(target.Requires, dto.TransitionDataDTO{ReasonCode: t.ReasonCode})
+			if len(missing) > 0 {
+				return fmt.Errorf("timeout: %q exige %s", t.To, strings.Join(missing, ", "))
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("timeout: acción desconocida %q", t.Action)
+	}
+}
+
+// reachableFrom recorre el grafo (con las transiciones de todos los roles)
+func (d *WorkflowDefinition) reachableFrom(start string) map[string]bool {
+	edges := make(map[string][]string, len(d.States))
+	for _, st := range d.States {
+		for _, targets := range st.Transitions {
+			edges[st.Name] = append(edges[st.Name], targets...)
+		}
+	}
+
+	reached := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[cur] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// CatalogStates convierte la definición al formato de state_catalog
+func (d *WorkflowDefinition) CatalogStates() []*model.CatalogState {
+	out := make([]*model.CatalogState, 0, len(d.States))
+	for _, st := range d.States {
+		transitions := st.Transitions
+		if transitions == nil {
+			transitions = map[string][]string{}
+		}
+		out = append(out, &model.CatalogState{
+			Workflow:         d.Name,
+			Name:             st.Name,
+			Initial:          st.Name == d.Initial,
+			Final:            st.Final,
+			NotifiesCustomer: st.NotifiesCustomer,
+			Returnable:       st.Returnable,
+			Requires:         st.Requires,
+			ItemsRequire:     st.ItemsRequire,
+			Timeout:          st.Timeout,
+			Transitions:      transitions,
+		})
+	}
+	return out
+}